@@ -0,0 +1,204 @@
+package gemini
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+type PooledOrder struct {
+	Name string
+	Order
+}
+
+// AccountPool holds several configured Apis by name, such as one key per
+// strategy or per sub-account. Apis added in the same rate limit group share
+// a RateLimiter, for keys whose requests Gemini counts together, and Apis
+// added for the same account are only counted once when balances and orders
+// are aggregated.
+type AccountPool struct {
+	mu       sync.RWMutex
+	apis     map[string]*Api
+	accounts map[string]string
+	limiters map[string]*RateLimiter
+}
+
+func NewAccountPool() *AccountPool {
+	return &AccountPool{
+		apis:     make(map[string]*Api),
+		accounts: make(map[string]string),
+		limiters: make(map[string]*RateLimiter),
+	}
+}
+
+// Add adds an Api to the pool under a name. Apis given the same
+// rateLimitGroup share a rate limit budget, and of the Apis given the same
+// account only the first by name is used for Balances and ActiveOrders. An
+// empty rateLimitGroup or account gives the Api a budget or an account of
+// its own.
+func (p *AccountPool) Add(name, rateLimitGroup, account string, api *Api) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if rateLimitGroup == "" {
+		rateLimitGroup = "name:" + name
+	}
+	if account == "" {
+		account = "name:" + name
+	}
+
+	limiter, ok := p.limiters[rateLimitGroup]
+	if !ok {
+		limiter = NewRateLimiter(PRIVATE_REQUESTS_PER_SECOND, PRIVATE_REQUESTS_BURST)
+		p.limiters[rateLimitGroup] = limiter
+	}
+
+	api.SetRateLimiter(limiter)
+
+	p.apis[name] = api
+	p.accounts[name] = account
+}
+
+// Get returns the Api with the given name.
+func (p *AccountPool) Get(name string) (*Api, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	api, ok := p.apis[name]
+	if !ok {
+		return nil, fmt.Errorf("no api named %v in pool", name)
+	}
+
+	return api, nil
+}
+
+// Names returns the names of the Apis in the pool, sorted.
+func (p *AccountPool) Names() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.sortedNames()
+}
+
+// Balances returns the balances of one Api per account, keyed by name.
+func (p *AccountPool) Balances() (map[string][]FundBalance, error) {
+
+	res := make(map[string][]FundBalance)
+	var mu sync.Mutex
+
+	err := p.each(func(name string, api *Api) error {
+		balances, err := api.Balances()
+		if err != nil {
+			return fmt.Errorf("%v: %v", name, err)
+		}
+		mu.Lock()
+		res[name] = balances
+		mu.Unlock()
+		return nil
+	})
+
+	return res, err
+}
+
+// TotalBalances returns the balances summed by currency across all accounts.
+func (p *AccountPool) TotalBalances() ([]FundBalance, error) {
+
+	byName, err := p.Balances()
+	if err != nil {
+		return nil, err
+	}
+
+	totals := make(map[string]*FundBalance)
+	var currencies []string
+
+	for _, balances := range byName {
+		for _, balance := range balances {
+			total, ok := totals[balance.Currency]
+			if !ok {
+				total = &FundBalance{Type: balance.Type, Currency: balance.Currency}
+				totals[balance.Currency] = total
+				currencies = append(currencies, balance.Currency)
+			}
+			total.Amount += balance.Amount
+			total.Available += balance.Available
+			total.AvailableForWithdrawal += balance.AvailableForWithdrawal
+		}
+	}
+
+	sort.Strings(currencies)
+
+	res := make([]FundBalance, 0, len(currencies))
+	for _, currency := range currencies {
+		res = append(res, *totals[currency])
+	}
+
+	return res, nil
+}
+
+// ActiveOrders returns the live orders of every account, each tagged with
+// the name of the Api it was fetched through.
+func (p *AccountPool) ActiveOrders() ([]PooledOrder, error) {
+
+	var res []PooledOrder
+	var mu sync.Mutex
+
+	err := p.each(func(name string, api *Api) error {
+		orders, err := api.ActiveOrders()
+		if err != nil {
+			return fmt.Errorf("%v: %v", name, err)
+		}
+		mu.Lock()
+		for _, order := range orders {
+			res = append(res, PooledOrder{Name: name, Order: order})
+		}
+		mu.Unlock()
+		return nil
+	})
+
+	return res, err
+}
+
+// each calls fn concurrently for the first Api, by name, of every account and
+// returns the first error.
+func (p *AccountPool) each(fn func(name string, api *Api) error) error {
+
+	p.mu.RLock()
+	seen := make(map[string]bool)
+	apis := make(map[string]*Api)
+	for _, name := range p.sortedNames() {
+		if account := p.accounts[name]; !seen[account] {
+			seen[account] = true
+			apis[name] = p.apis[name]
+		}
+	}
+	p.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(apis))
+
+	for name, api := range apis {
+		wg.Add(1)
+		go func(name string, api *Api) {
+			defer wg.Done()
+			if err := fn(name, api); err != nil {
+				errs <- err
+			}
+		}(name, api)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	return <-errs
+}
+
+// sortedNames returns the names in the pool, sorted. The caller must hold
+// the lock.
+func (p *AccountPool) sortedNames() []string {
+	names := make([]string, 0, len(p.apis))
+	for name := range p.apis {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,129 @@
+package gemini
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Gemini rejects nonces based on a timestamp that are too far from its own
+// time, so skew beyond this is worth knowing about well before then.
+const DEFAULT_CLOCK_SKEW_THRESHOLD = 2 * time.Second
+
+// weight given to each new offset sample
+const clockSmoothing = 0.2
+
+// serverClock estimates the offset of Gemini's clock from the local clock. It
+// is shared by the copies returned from Account and WithContext.
+type serverClock struct {
+	mu        sync.Mutex
+	offset    time.Duration
+	samples   int
+	threshold time.Duration
+	skewed    bool
+}
+
+// observe adds an offset sample and reports whether the estimate has just
+// crossed the threshold in either direction.
+func (c *serverClock) observe(sample time.Duration) (offset time.Duration, crossed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.samples == 0 {
+		c.offset = sample
+	} else {
+		c.offset += time.Duration(clockSmoothing * float64(sample-c.offset))
+	}
+	c.samples++
+
+	skewed := c.offset > c.threshold || c.offset < -c.threshold
+	crossed = skewed != c.skewed
+	c.skewed = skewed
+
+	return c.offset, crossed
+}
+
+func (c *serverClock) get() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.offset
+}
+
+// ClockSkew returns the estimated offset of Gemini's clock from the local
+// clock; positive when Gemini is ahead. It is estimated from the Date header
+// of every response and from timestamps passed to ObserveServerTimestamp, and
+// is zero until the first request.
+func (api *Api) ClockSkew() time.Duration {
+	return api.clock.get()
+}
+
+// ServerTime returns the current time according to Gemini.
+func (api *Api) ServerTime() time.Time {
+	return time.Now().Add(api.ClockSkew())
+}
+
+// SetClockSkewThreshold sets the skew beyond which a warning is logged.
+func (api *Api) SetClockSkewThreshold(threshold time.Duration) {
+	api.clock.mu.Lock()
+	defer api.clock.mu.Unlock()
+	api.clock.threshold = threshold
+}
+
+// ObserveServerTimestamp adds a sample to the clock skew estimate from a
+// timestampms value just received from Gemini, such as that of a websocket
+// event. Such timestamps include the network latency, so they slightly
+// understate how far ahead Gemini is.
+func (api *Api) ObserveServerTimestamp(timestampms int64) {
+	server := time.Unix(0, timestampms*int64(time.Millisecond))
+	api.observeClock(server.Sub(time.Now()))
+}
+
+func (api *Api) observeClock(sample time.Duration) {
+
+	offset, crossed := api.clock.observe(sample)
+	if !crossed || api.logger == nil {
+		return
+	}
+
+	api.clock.mu.Lock()
+	threshold := api.clock.threshold
+	api.clock.mu.Unlock()
+
+	level, msg := slog.LevelWarn, "gemini clock skew exceeds threshold"
+	if offset <= threshold && offset >= -threshold {
+		level, msg = slog.LevelInfo, "gemini clock skew back within threshold"
+	}
+
+	api.logger.Log(api.context(), level, msg,
+		slog.Duration("skew", offset),
+		slog.Duration("threshold", threshold))
+}
+
+// clockMiddleware estimates the clock offset from the Date header of each
+// response. The header has a resolution of a second, so the server time is
+// taken as the middle of that second and compared with the middle of the
+// request.
+func (api *Api) clockMiddleware(next Handler) Handler {
+	return func(req *Request) (*Response, error) {
+
+		sent := time.Now()
+		resp, err := next(req)
+		received := time.Now()
+
+		if resp == nil {
+			return resp, err
+		}
+
+		date, perr := http.ParseTime(resp.Header.Get("Date"))
+		if perr != nil {
+			return resp, err
+		}
+
+		local := sent.Add(received.Sub(sent) / 2)
+		server := date.Add(500 * time.Millisecond)
+		api.observeClock(server.Sub(local))
+
+		return resp, err
+	}
+}
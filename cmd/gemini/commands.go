@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jsgoyette/gemini"
+)
+
+type bookRow struct {
+	Side   string
+	Price  float64
+	Amount float64
+}
+
+// run executes a command and returns its result for rendering.
+func run(api *gemini.Api, cmd string, args []string) (interface{}, error) {
+
+	switch cmd {
+	case "symbols":
+		return api.Symbols()
+
+	case "ticker":
+		symbol, err := parse(cmd, args, 1, nil)
+		if err != nil {
+			return nil, err
+		}
+		return api.Ticker(symbol[0])
+
+	case "book":
+		var bids, asks int
+		symbol, err := parse(cmd, args, 1, func(fs *flag.FlagSet) {
+			fs.IntVar(&bids, "bids", 0, "number of bids, 0 for all")
+			fs.IntVar(&asks, "asks", 0, "number of asks, 0 for all")
+		})
+		if err != nil {
+			return nil, err
+		}
+		book, err := api.OrderBook(symbol[0], bids, asks)
+		if err != nil {
+			return nil, err
+		}
+		var rows []bookRow
+		for i := len(book.Asks) - 1; i >= 0; i-- {
+			rows = append(rows, bookRow{"ask", book.Asks[i].Price, book.Asks[i].Amount})
+		}
+		for _, entry := range book.Bids {
+			rows = append(rows, bookRow{"bid", entry.Price, entry.Amount})
+		}
+		return rows, nil
+
+	case "trades":
+		var since int64
+		var limit int
+		var breaks bool
+		symbol, err := parse(cmd, args, 1, func(fs *flag.FlagSet) {
+			fs.Int64Var(&since, "since", 0, "only trades after this timestamp")
+			fs.IntVar(&limit, "limit", 50, "max number of trades")
+			fs.BoolVar(&breaks, "breaks", false, "include broken trades")
+		})
+		if err != nil {
+			return nil, err
+		}
+		return api.Trades(symbol[0], since, limit, breaks)
+
+	case "auction":
+		var history, indicative bool
+		var since int64
+		var limit int
+		symbol, err := parse(cmd, args, 1, func(fs *flag.FlagSet) {
+			fs.BoolVar(&history, "history", false, "show past auctions")
+			fs.Int64Var(&since, "since", 0, "only auctions after this timestamp")
+			fs.IntVar(&limit, "limit", 50, "max number of auctions")
+			fs.BoolVar(&indicative, "indicative", false, "include indicative prices")
+		})
+		if err != nil {
+			return nil, err
+		}
+		if history {
+			return api.AuctionHistory(symbol[0], since, limit, indicative)
+		}
+		return api.CurrentAuction(symbol[0])
+
+	case "orders":
+		return api.ActiveOrders()
+
+	case "order":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("order: expected status, new, cancel or cancel-all")
+		}
+		return runOrder(api, args[0], args[1:])
+
+	case "balances":
+		return api.Balances()
+
+	case "deposit-address":
+		var label string
+		var create bool
+		network, err := parse(cmd, args, 1, func(fs *flag.FlagSet) {
+			fs.StringVar(&label, "label", "", "label of the address")
+			fs.BoolVar(&create, "new", false, "always create a new address")
+		})
+		if err != nil {
+			return nil, err
+		}
+		if create {
+			return api.NewDepositAddress(network[0], label)
+		}
+		return api.DepositAddress(network[0], label)
+
+	case "withdraw":
+		var req gemini.WithdrawRequest
+		var yes bool
+		pos, err := parse(cmd, args, 3, func(fs *flag.FlagSet) {
+			fs.StringVar(&req.Network, "network", "", "network for tokens on more than one chain")
+			fs.StringVar(&req.Memo, "memo", "", "memo or destination tag")
+			fs.StringVar(&req.ClientTransferId, "client-transfer-id", "", "client transfer id")
+			fs.BoolVar(&yes, "yes", false, "withdraw without asking for confirmation")
+		})
+		if err != nil {
+			return nil, err
+		}
+		req.Currency, req.Address = pos[0], pos[1]
+		if req.Amount, err = strconv.ParseFloat(pos[2], 64); err != nil {
+			return nil, fmt.Errorf("withdraw: invalid amount %q", pos[2])
+		}
+		if !yes {
+			fee, err := api.WithdrawalFeeEstimate(req)
+			if err != nil {
+				return nil, err
+			}
+			prompt := fmt.Sprintf("withdraw %v %v to %v, fee %v %v?",
+				pos[2], req.Currency, req.Address, fee.Fee.Value, fee.Fee.Currency)
+			if !confirm(prompt) {
+				return nil, fmt.Errorf("withdraw: not confirmed")
+			}
+			req.Fee = &fee
+		}
+		return api.Withdraw(req)
+	}
+
+	return nil, fmt.Errorf("unknown command %q", cmd)
+}
+
+func runOrder(api *gemini.Api, cmd string, args []string) (interface{}, error) {
+
+	switch cmd {
+	case "status":
+		id, err := parse("order status", args, 1, nil)
+		if err != nil {
+			return nil, err
+		}
+		return api.OrderStatus(id[0])
+
+	case "new":
+		var symbol, side, clientOrderId, options string
+		var amount, price float64
+		_, err := parse("order new", args, 0, func(fs *flag.FlagSet) {
+			fs.StringVar(&symbol, "symbol", "", "symbol to trade")
+			fs.StringVar(&side, "side", "", "buy or sell")
+			fs.Float64Var(&amount, "amount", 0, "amount to trade")
+			fs.Float64Var(&price, "price", 0, "limit price")
+			fs.StringVar(&clientOrderId, "client-id", "", "client order id")
+			fs.StringVar(&options, "options", "", "comma separated order options")
+		})
+		if err != nil {
+			return nil, err
+		}
+		if symbol == "" || side == "" || amount == 0 || price == 0 {
+			return nil, fmt.Errorf("order new: -symbol, -side, -amount and -price are required")
+		}
+		var opts []string
+		if options != "" {
+			opts = strings.Split(options, ",")
+		}
+		return api.NewOrder(symbol, clientOrderId, amount, price, side, opts)
+
+	case "cancel":
+		id, err := parse("order cancel", args, 1, nil)
+		if err != nil {
+			return nil, err
+		}
+		return api.CancelOrder(id[0])
+
+	case "cancel-all":
+		res, err := api.CancelAll()
+		if err != nil {
+			return nil, err
+		}
+		return res.Details, nil
+	}
+
+	return nil, fmt.Errorf("unknown order command %q", cmd)
+}
+
+// confirm asks a yes or no question on the terminal. Anything but yes,
+// including no input at all, is taken as no.
+func confirm(prompt string) bool {
+
+	fmt.Fprintf(os.Stderr, "%v [y/N] ", prompt)
+
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// parse parses the flags for a command, which may appear before or after its
+// positional arguments, and checks the number of positional arguments.
+func parse(cmd string, args []string, n int, setup func(*flag.FlagSet)) ([]string, error) {
+
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	if setup != nil {
+		setup(fs)
+	}
+
+	var pos []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		pos = append(pos, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	if len(pos) != n {
+		return nil, fmt.Errorf("%v: expected %d arguments, got %d", cmd, n, len(pos))
+	}
+
+	return pos, nil
+}
@@ -0,0 +1,188 @@
+// Command gemini is a command-line client for the Gemini exchange api.
+//
+// Credentials are read from a JSON or YAML config file given with -config or
+// GEMINI_CONFIG:
+//
+//	{"key": "...", "secret": "...", "live": false}
+//
+// or otherwise from the GEMINI_API_KEY and GEMINI_API_SECRET environment
+// variables. GEMINI_LIVE overrides the live setting of the config file, and
+// -live always uses the live exchange. With -log, requests and websocket
+// streams are logged to stderr at the given level.
+//
+// Usage:
+//
+//	gemini [-live] [-format table|json|csv] [-config file] [-log level] <command> [args]
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jsgoyette/gemini"
+)
+
+const usage = `usage: gemini [-live] [-format table|json|csv] [-config file] [-log level] <command> [args]
+
+public commands:
+  symbols
+  ticker <symbol>
+  book <symbol> [-bids n] [-asks n]
+  trades <symbol> [-since ts] [-limit n] [-breaks]
+  auction <symbol> [-history] [-since ts] [-limit n] [-indicative]
+
+private commands:
+  orders
+  order status <order id>
+  order new -symbol s -side buy|sell -amount n -price n [-client-id id] [-options opt,...]
+  order cancel <order id>
+  order cancel-all
+  balances
+  deposit-address <network> [-label label] [-new]
+  withdraw <currency> <address> <amount> [-network n] [-memo m] [-client-transfer-id id] [-yes]
+
+streaming:
+  stream market <symbol> [-trades]
+  stream orders [-symbols s,...] [-types t,...]
+
+interactive:
+  watch <symbol> [-depth n] [-trades n] [-interval d] [-mine=false]
+`
+
+func main() {
+
+	flags := flag.NewFlagSet("gemini", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+
+	live := flags.Bool("live", false, "use the live exchange instead of the sandbox")
+	format := flags.String("format", "table", "output format: table, json or csv")
+	configFile := flags.String("config", os.Getenv("GEMINI_CONFIG"), "path to a JSON or YAML config file")
+	logLevel := flags.String("log", "", "log to stderr at this level: debug, info, warn or error")
+
+	flags.Parse(os.Args[1:])
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	useLive, err := loadLive(*configFile)
+	if err != nil {
+		fatal(err)
+	}
+	if env, err := strconv.ParseBool(os.Getenv("GEMINI_LIVE")); err == nil {
+		useLive = env
+	}
+	if *live {
+		useLive = true
+	}
+
+	api := gemini.New(useLive, "", "")
+
+	if *logLevel != "" {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+			fatal(fmt.Errorf("invalid -log level %q", *logLevel))
+		}
+		api.SetLogger(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+	}
+
+	// public commands work without credentials
+	creds, err := loadCredentials(*configFile)
+	if err == nil {
+		api.SetCredentials(creds)
+	} else if *configFile != "" {
+		fatal(err)
+	}
+	authenticated := err == nil
+
+	wsURL := gemini.WS_SANDBOX_URL
+	if useLive {
+		wsURL = gemini.WS_BASE_URL
+	}
+
+	if flags.Arg(0) == "stream" {
+		if err := stream(api, wsURL, *format, os.Stdout, flags.Args()[1:]); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	if flags.Arg(0) == "watch" {
+		if err := watch(api, wsURL, authenticated, os.Stdout, flags.Args()[1:]); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	res, err := run(api, flags.Arg(0), flags.Args()[1:])
+	if err != nil {
+		fatal(err)
+	}
+
+	if err := render(os.Stdout, *format, res); err != nil {
+		fatal(err)
+	}
+}
+
+// loadCredentials reads credentials from the config file if one is given,
+// and otherwise from the environment.
+func loadCredentials(path string) (gemini.Credentials, error) {
+	if path != "" {
+		return gemini.LoadCredentialsFile(path)
+	}
+	return gemini.EnvCredentials()
+}
+
+// loadLive reads the live setting from the config file, if one is given. The
+// file is read as JSON, or as flat YAML if it ends in .yaml or .yml, as with
+// gemini.LoadCredentialsFile.
+func loadLive(path string) (bool, error) {
+
+	if path == "" {
+		return false, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		for _, line := range strings.Split(string(b), "\n") {
+			idx := strings.Index(line, ":")
+			if idx == -1 || strings.TrimSpace(line[:idx]) != "live" {
+				continue
+			}
+			value := strings.Trim(strings.TrimSpace(line[idx+1:]), `"'`)
+			live, err := strconv.ParseBool(value)
+			if err != nil {
+				return false, fmt.Errorf("%v: invalid live value %q", path, value)
+			}
+			return live, nil
+		}
+		return false, nil
+	}
+
+	var cfg struct {
+		Live bool `json:"live"`
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return false, fmt.Errorf("%v: %v", path, err)
+	}
+
+	return cfg.Live, nil
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "gemini:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// render writes v in the given format. Slices are written one row per
+// element; a single struct is written as one row in csv, and as a list of
+// field and value pairs in a table.
+func render(w io.Writer, format string, v interface{}) error {
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+
+	case "csv":
+		header, rows := tabulate(v)
+		cw := csv.NewWriter(w)
+		cw.Write(header)
+		cw.WriteAll(rows)
+		return cw.Error()
+
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		header, rows := tabulate(v)
+
+		if reflect.Indirect(reflect.ValueOf(v)).Kind() == reflect.Struct && len(rows) == 1 {
+			for i, name := range header {
+				fmt.Fprintf(tw, "%v\t%v\n", name, rows[0][i])
+			}
+			return tw.Flush()
+		}
+
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	}
+
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// rowWriter writes a stream of values as they arrive. In json format each
+// message is written on its own line as received; in csv and table formats
+// the header is written before the first row.
+type rowWriter struct {
+	w      io.Writer
+	format string
+	header bool
+	cw     *csv.Writer
+	tw     *tabwriter.Writer
+}
+
+func newRowWriter(w io.Writer, format string) (*rowWriter, error) {
+
+	rw := &rowWriter{w: w, format: format}
+
+	switch format {
+	case "json":
+	case "csv":
+		rw.cw = csv.NewWriter(w)
+	case "table":
+		// a minimum cell width keeps columns roughly aligned between rows,
+		// which are flushed as they arrive
+		rw.tw = tabwriter.NewWriter(w, 12, 4, 2, ' ', 0)
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+
+	return rw, nil
+}
+
+// json reports whether messages should be written with raw rather than
+// converted to rows.
+func (rw *rowWriter) json() bool {
+	return rw.format == "json"
+}
+
+// raw writes a JSON message on a single line.
+func (rw *rowWriter) raw(msg []byte) error {
+	var b bytes.Buffer
+	if err := json.Compact(&b, msg); err != nil {
+		return err
+	}
+	b.WriteByte('\n')
+	_, err := rw.w.Write(b.Bytes())
+	return err
+}
+
+// row writes a struct as a row of csv or table output.
+func (rw *rowWriter) row(row interface{}) error {
+
+	header, rows := tabulate(row)
+
+	if rw.cw != nil {
+		if !rw.header {
+			rw.cw.Write(header)
+			rw.header = true
+		}
+		rw.cw.WriteAll(rows)
+		return rw.cw.Error()
+	}
+
+	if !rw.header {
+		fmt.Fprintln(rw.tw, strings.Join(header, "\t"))
+		rw.header = true
+	}
+	for _, r := range rows {
+		fmt.Fprintln(rw.tw, strings.Join(r, "\t"))
+	}
+	return rw.tw.Flush()
+}
+
+// tabulate converts a struct, or slice of structs or values, into a header
+// and rows of strings. Embedded structs are flattened; other nested values
+// are formatted with %v.
+func tabulate(v interface{}) ([]string, [][]string) {
+
+	rv := reflect.Indirect(reflect.ValueOf(v))
+
+	if rv.Kind() != reflect.Slice {
+		header, row := flatten(rv)
+		return header, [][]string{row}
+	}
+
+	var header []string
+	var rows [][]string
+
+	for i := 0; i < rv.Len(); i++ {
+		h, row := flatten(reflect.Indirect(rv.Index(i)))
+		header = h
+		rows = append(rows, row)
+	}
+
+	if header == nil {
+		header, _ = flatten(reflect.Zero(rv.Type().Elem()))
+	}
+
+	return header, rows
+}
+
+func flatten(rv reflect.Value) ([]string, []string) {
+
+	if rv.Kind() != reflect.Struct {
+		return []string{"value"}, []string{format(rv)}
+	}
+
+	var header, row []string
+
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Type().Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if field.Anonymous && rv.Field(i).Kind() == reflect.Struct {
+			h, r := flatten(rv.Field(i))
+			header = append(header, h...)
+			row = append(row, r...)
+			continue
+		}
+
+		header = append(header, field.Name)
+		row = append(row, format(rv.Field(i)))
+	}
+
+	return header, row
+}
+
+func format(rv reflect.Value) string {
+	if rv.Kind() == reflect.Float64 {
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", rv.Interface())
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/jsgoyette/gemini"
+)
+
+type marketRow struct {
+	EventId   gemini.Id
+	Type      string
+	Side      string
+	Price     float64
+	Remaining float64
+	Delta     float64
+	Amount    float64
+	Reason    string
+}
+
+type orderEventRow struct {
+	Type            string
+	OrderId         gemini.Id
+	ClientOrderId   string
+	Symbol          string
+	Side            string
+	Price           float64
+	ExecutedAmount  float64
+	RemainingAmount float64
+	FillPrice       float64
+	FillAmount      float64
+	Reason          string
+}
+
+// stream prints market data or order events from the websocket api as they
+// arrive, until interrupted. In json format each message is written as a
+// line of JSON as received from Gemini; in table and csv formats each event
+// is written as a row.
+func stream(api *gemini.Api, wsURL, format string, w io.Writer, args []string) error {
+
+	if len(args) == 0 {
+		return fmt.Errorf("stream: expected market or orders")
+	}
+
+	out, err := newRowWriter(w, format)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "market":
+		return streamMarket(api, wsURL, out, args[1:])
+	case "orders":
+		return streamOrders(api, wsURL, out, args[1:])
+	}
+
+	return fmt.Errorf("unknown stream %q", args[0])
+}
+
+func streamMarket(api *gemini.Api, wsURL string, out *rowWriter, args []string) error {
+
+	var tradesOnly bool
+	symbol, err := parse("stream market", args, 1, func(fs *flag.FlagSet) {
+		fs.BoolVar(&tradesOnly, "trades", false, "only show trades")
+	})
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	if tradesOnly {
+		query.Set("trades", "true")
+	}
+
+	conn, err := dialMarketData(api, wsURL, symbol[0], query)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return readUntilInterrupt(conn, func(msg []byte) error {
+
+		var md gemini.MarketData
+		if err := json.Unmarshal(msg, &md); err != nil {
+			return err
+		}
+
+		if md.Type != "update" {
+			return nil
+		}
+		if out.json() {
+			return out.raw(msg)
+		}
+
+		for _, ev := range md.Events {
+			side := ev.Side
+			if ev.Type == "trade" {
+				side = ev.MakerSide
+			}
+			err := out.row(marketRow{
+				EventId:   md.EventId,
+				Type:      ev.Type,
+				Side:      side,
+				Price:     ev.Price,
+				Remaining: ev.Remaining,
+				Delta:     ev.Delta,
+				Amount:    ev.Amount,
+				Reason:    ev.Reason,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func streamOrders(api *gemini.Api, wsURL string, out *rowWriter, args []string) error {
+
+	var symbols, types string
+	_, err := parse("stream orders", args, 0, func(fs *flag.FlagSet) {
+		fs.StringVar(&symbols, "symbols", "", "comma separated symbols to show")
+		fs.StringVar(&types, "types", "", "comma separated event types to show")
+	})
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	for _, symbol := range split(symbols) {
+		query.Add("symbolFilter", symbol)
+	}
+	for _, typ := range split(types) {
+		query.Add("eventTypeFilter", typ)
+	}
+
+	header, err := api.SignHeader(&map[string]interface{}{
+		"request": gemini.ORDER_EVENTS_URI,
+		"nonce":   gemini.Nonce(),
+	})
+	if err != nil {
+		return err
+	}
+
+	conn, err := dial(api, wsURL+gemini.ORDER_EVENTS_URI, query, header)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return readUntilInterrupt(conn, func(msg []byte) error {
+
+		// events arrive as a list; subscription acknowledgements and
+		// heartbeats as a single object
+		var events []gemini.OrderEvent
+		if len(msg) == 0 || msg[0] != '[' {
+			return nil
+		}
+		if out.json() {
+			return out.raw(msg)
+		}
+		if err := json.Unmarshal(msg, &events); err != nil {
+			return err
+		}
+
+		for _, ev := range events {
+			err := out.row(orderEventRow{
+				Type:            ev.Type,
+				OrderId:         ev.OrderId,
+				ClientOrderId:   ev.ClientOrderId,
+				Symbol:          ev.Symbol,
+				Side:            ev.Side,
+				Price:           ev.Price,
+				ExecutedAmount:  ev.ExecutedAmount,
+				RemainingAmount: ev.RemainingAmount,
+				FillPrice:       ev.Fill.Price,
+				FillAmount:      ev.Fill.Amount,
+				Reason:          ev.Reason,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// wsConn is a websocket connection that logs through the Logger of the Api
+// it was opened for.
+type wsConn struct {
+	*websocket.Conn
+	api *gemini.Api
+	url string
+}
+
+// dialMarketData connects to the market data stream for a symbol with
+// heartbeats enabled.
+func dialMarketData(api *gemini.Api, wsURL, symbol string, query url.Values) (*wsConn, error) {
+	query.Set("heartbeat", "true")
+	return dial(api, wsURL+gemini.MARKET_DATA_URI+symbol, query, nil)
+}
+
+func dial(api *gemini.Api, rawurl string, query url.Values, header http.Header) (*wsConn, error) {
+
+	if len(query) > 0 {
+		rawurl += "?" + query.Encode()
+	}
+
+	conn, resp, err := websocket.DefaultDialer.Dial(rawurl, header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("%v: %v", rawurl, resp.Status)
+		}
+		return nil, err
+	}
+
+	api.LogStreamConnect(rawurl)
+
+	return &wsConn{Conn: conn, api: api, url: rawurl}, nil
+}
+
+// readMessages reads from the connection in the background, sending each
+// message on the returned channel. The error that ends reading is sent on
+// the error channel.
+func readMessages(conn *wsConn) (<-chan []byte, <-chan error) {
+
+	messages := make(chan []byte)
+	errs := make(chan error, 1)
+
+	go func() {
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				errs <- err
+				return
+			}
+			conn.api.LogStreamFrame(conn.url, msg)
+			messages <- msg
+		}
+	}()
+
+	return messages, errs
+}
+
+// readUntilInterrupt passes each message from the connection to handle until
+// the process is interrupted, when the connection is closed cleanly.
+func readUntilInterrupt(conn *wsConn, handle func([]byte) error) error {
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	messages, errs := readMessages(conn)
+
+	for {
+		select {
+		case <-interrupt:
+			return closeConn(conn)
+		case err := <-errs:
+			conn.api.LogStreamDisconnect(conn.url, err)
+			return err
+		case msg := <-messages:
+			if err := handle(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// closeConn closes the connection cleanly.
+func closeConn(conn *wsConn) error {
+	conn.api.LogStreamDisconnect(conn.url, nil)
+	return conn.WriteMessage(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+}
+
+func split(list string) []string {
+	if list == "" {
+		return nil
+	}
+	return strings.Split(list, ",")
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jsgoyette/gemini"
+)
+
+const (
+	clearScreen = "\x1b[H\x1b[2J"
+	hideCursor  = "\x1b[?25l"
+	showCursor  = "\x1b[?25h"
+	red         = "\x1b[31m"
+	green       = "\x1b[32m"
+	reverse     = "\x1b[7m"
+	reset       = "\x1b[0m"
+
+	barWidth = 30
+)
+
+type viewer struct {
+	api    *gemini.Api
+	wsURL  string
+	symbol string
+	depth  int
+	tape   int
+	mine   bool
+
+	book   gemini.Book
+	trades []gemini.Trade
+	orders map[float64][]gemini.Order
+
+	// the last failure of the market data stream and of ActiveOrders,
+	// shown until the next success
+	streamErr error
+	ordersErr error
+}
+
+// watch renders a live ladder of the order book for a symbol with depth
+// bars, the spread and mid, a tape of recent trades, and the user's own open
+// orders highlighted at their price levels. The book and trades are kept
+// from the market data stream; open orders are polled with ActiveOrders. It
+// redraws on an interval until interrupted, reconnecting to the stream and
+// retrying ActiveOrders after any failure.
+func watch(api *gemini.Api, wsURL string, authenticated bool, w io.Writer, args []string) error {
+
+	v := &viewer{api: api, wsURL: wsURL}
+
+	var interval time.Duration
+	pos, err := parse("watch", args, 1, func(fs *flag.FlagSet) {
+		fs.IntVar(&v.depth, "depth", 10, "price levels on each side")
+		fs.IntVar(&v.tape, "trades", 15, "trades shown in the tape")
+		fs.BoolVar(&v.mine, "mine", true, "highlight your open orders, requires credentials")
+		fs.DurationVar(&interval, "interval", time.Second, "redraw and open orders refresh interval")
+	})
+	if err != nil {
+		return err
+	}
+	if interval <= 0 {
+		return fmt.Errorf("watch: -interval must be greater than zero")
+	}
+	v.symbol = pos[0]
+
+	// without credentials the ladder is still useful
+	v.mine = v.mine && authenticated
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	fmt.Fprint(w, hideCursor)
+	defer fmt.Fprint(w, showCursor)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var conn *wsConn
+	var messages <-chan []byte
+	var errs <-chan error
+
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	for {
+		if conn == nil {
+			if conn, err = v.connect(); err == nil {
+				messages, errs = readMessages(conn)
+			}
+		}
+
+		v.refreshOrders()
+		v.draw(w)
+
+	wait:
+		for {
+			select {
+			case <-interrupt:
+				if conn != nil {
+					closeConn(conn)
+				}
+				return nil
+			case msg := <-messages:
+				v.apply(msg)
+			case err := <-errs:
+				v.streamErr = err
+				v.api.LogStreamDisconnect(conn.url, err)
+				conn.Close()
+				conn, messages, errs = nil, nil, nil
+			case <-ticker.C:
+				break wait
+			}
+		}
+	}
+}
+
+// connect subscribes to the market data stream, which starts with the full
+// book, so the book is cleared.
+func (v *viewer) connect() (*wsConn, error) {
+
+	conn, err := dialMarketData(v.api, v.wsURL, v.symbol, url.Values{})
+	if err != nil {
+		v.streamErr = err
+		return nil, err
+	}
+
+	v.streamErr = nil
+	v.book = gemini.Book{}
+
+	return conn, nil
+}
+
+// apply updates the book and trade tape from a market data message.
+func (v *viewer) apply(msg []byte) {
+
+	var md gemini.MarketData
+	if err := json.Unmarshal(msg, &md); err != nil {
+		v.streamErr = err
+		return
+	}
+
+	for _, ev := range md.Events {
+		switch ev.Type {
+		case "change":
+			if ev.Side == "bid" {
+				v.book.Bids.Set(ev.Price, ev.Remaining)
+			} else {
+				v.book.Asks.Set(ev.Price, ev.Remaining)
+			}
+		case "trade":
+			v.addTrade(ev)
+		}
+	}
+}
+
+// addTrade adds a trade to the front of the tape. The side shown is the
+// taker's, as in Trades.
+func (v *viewer) addTrade(ev gemini.MarketEvent) {
+
+	side := "buy"
+	if ev.MakerSide == "bid" {
+		side = "sell"
+	}
+
+	trade := gemini.Trade{
+		TradeId:   ev.TradeId,
+		Timestamp: time.Now().UnixNano() / int64(time.Millisecond),
+		Type:      side,
+		Price:     ev.Price,
+		Amount:    ev.Amount,
+	}
+
+	v.trades = append([]gemini.Trade{trade}, v.trades...)
+	if len(v.trades) > v.tape {
+		v.trades = v.trades[:v.tape]
+	}
+}
+
+// refreshOrders polls the user's open orders. On failure the last known
+// orders are kept and the error is shown until the next success.
+func (v *viewer) refreshOrders() {
+
+	if !v.mine {
+		return
+	}
+
+	orders, err := v.api.ActiveOrders()
+	if err != nil {
+		v.ordersErr = err
+		return
+	}
+	v.ordersErr = nil
+
+	v.orders = make(map[float64][]gemini.Order)
+	for _, order := range orders {
+		if strings.EqualFold(order.Symbol, v.symbol) {
+			v.orders[order.Price] = append(v.orders[order.Price], order)
+		}
+	}
+}
+
+func (v *viewer) draw(w io.Writer) {
+
+	asks := append(gemini.BookEntries(nil), v.book.Asks...)
+	bids := append(gemini.BookEntries(nil), v.book.Bids...)
+
+	sort.Slice(asks, func(i, j int) bool { return asks[i].Price > asks[j].Price })
+	sort.Slice(bids, func(i, j int) bool { return bids[i].Price > bids[j].Price })
+
+	if len(asks) > v.depth {
+		asks = asks[len(asks)-v.depth:]
+	}
+	if len(bids) > v.depth {
+		bids = bids[:v.depth]
+	}
+
+	var max float64
+	for _, entry := range append(asks, bids...) {
+		if entry.Amount > max {
+			max = entry.Amount
+		}
+	}
+
+	var b strings.Builder
+
+	fmt.Fprint(&b, clearScreen)
+	fmt.Fprintf(&b, "%v  %v\n\n", strings.ToUpper(v.symbol), time.Now().Format("15:04:05"))
+	fmt.Fprintf(&b, "%14v %14v  %v\n", "PRICE", "AMOUNT", "DEPTH")
+
+	for _, entry := range asks {
+		v.level(&b, red, entry, max)
+	}
+
+	bestAsk, bestBid := v.book.Asks.Lowest(), v.book.Bids.Highest()
+	if bestAsk.Price > 0 && bestBid.Price > 0 {
+		fmt.Fprintf(&b, "%14v spread %v  mid %v\n",
+			"", num(bestAsk.Price-bestBid.Price), num((bestAsk.Price+bestBid.Price)/2))
+	} else {
+		fmt.Fprintln(&b)
+	}
+
+	for _, entry := range bids {
+		v.level(&b, green, entry, max)
+	}
+
+	fmt.Fprintf(&b, "\n%-10v %5v %14v %14v\n", "TIME", "SIDE", "PRICE", "AMOUNT")
+	for _, trade := range v.trades {
+		color := green
+		if trade.Type == "sell" {
+			color = red
+		}
+		fmt.Fprintf(&b, "%-10v %v%5v %14v %14v%v\n",
+			time.Unix(0, trade.Timestamp*int64(time.Millisecond)).Format("15:04:05"),
+			color, trade.Type, num(trade.Price), num(trade.Amount), reset)
+	}
+
+	if v.streamErr != nil {
+		fmt.Fprintf(&b, "\n%vmarket data: %v, reconnecting%v\n", red, v.streamErr, reset)
+	}
+	if v.ordersErr != nil {
+		fmt.Fprintf(&b, "\n%vopen orders: %v, retrying%v\n", red, v.ordersErr, reset)
+	}
+
+	io.WriteString(w, b.String())
+}
+
+// level writes one price level of the ladder, highlighted if the user has
+// an open order at that price.
+func (v *viewer) level(b *strings.Builder, color string, entry gemini.BookEntry, max float64) {
+
+	var bar int
+	if max > 0 {
+		bar = int(entry.Amount / max * barWidth)
+	}
+
+	var mine string
+	if orders := v.orders[entry.Price]; len(orders) > 0 {
+		var amount float64
+		for _, order := range orders {
+			amount += order.RemainingAmount
+		}
+		color += reverse
+		mine = fmt.Sprintf("  < yours %v", num(amount))
+	}
+
+	fmt.Fprintf(b, "%v%14v %14v%v  %-*v%v\n",
+		color, num(entry.Price), num(entry.Amount), reset,
+		barWidth, strings.Repeat("█", bar), mine)
+}
+
+func num(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,173 @@
+package gemini
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	ENV_API_KEY    = "GEMINI_API_KEY"
+	ENV_API_SECRET = "GEMINI_API_SECRET"
+)
+
+type Credentials struct {
+	Key    string `json:"key"`
+	Secret string `json:"secret"`
+}
+
+// SecretProvider supplies credentials from outside the program, such as a
+// secrets manager. It is called again whenever credentials are refreshed, so
+// a provider may return rotated keys.
+type SecretProvider interface {
+	Credentials() (Credentials, error)
+}
+
+// FileSecretProvider reads credentials from a JSON or YAML file each time
+// they are requested, so replacing the file rotates the keys.
+type FileSecretProvider struct {
+	Path string
+}
+
+func (p FileSecretProvider) Credentials() (Credentials, error) {
+	return LoadCredentialsFile(p.Path)
+}
+
+// EnvCredentials reads credentials from the GEMINI_API_KEY and
+// GEMINI_API_SECRET environment variables.
+func EnvCredentials() (Credentials, error) {
+
+	creds := Credentials{
+		Key:    os.Getenv(ENV_API_KEY),
+		Secret: os.Getenv(ENV_API_SECRET),
+	}
+
+	if creds.Key == "" || creds.Secret == "" {
+		return creds, fmt.Errorf("%v and %v must both be set", ENV_API_KEY, ENV_API_SECRET)
+	}
+
+	return creds, nil
+}
+
+// LoadCredentialsFile reads credentials from a file with "key" and "secret"
+// fields. Files ending in .yaml or .yml are read as flat "name: value" YAML;
+// all others as JSON.
+func LoadCredentialsFile(path string) (Credentials, error) {
+
+	var creds Credentials
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return creds, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = parseYAMLCredentials(b, &creds)
+	default:
+		err = json.Unmarshal(b, &creds)
+	}
+	if err != nil {
+		return creds, fmt.Errorf("%v: %v", path, err)
+	}
+
+	if creds.Key == "" || creds.Secret == "" {
+		return creds, fmt.Errorf("%v: key and secret must both be set", path)
+	}
+
+	return creds, nil
+}
+
+func parseYAMLCredentials(b []byte, creds *Credentials) error {
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line == "---" {
+			continue
+		}
+
+		idx := strings.Index(line, ":")
+		if idx == -1 {
+			return fmt.Errorf("line %d: expected name: value", n)
+		}
+
+		name := strings.TrimSpace(line[:idx])
+		value := strings.Trim(strings.TrimSpace(line[idx+1:]), `"'`)
+
+		switch name {
+		case "key":
+			creds.Key = value
+		case "secret":
+			creds.Secret = value
+		}
+	}
+
+	return scanner.Err()
+}
+
+// credentialStore holds the credentials for an Api. It is shared by the
+// copies returned from Api.Account, so rotating keys applies to all of them.
+type credentialStore struct {
+	mu       sync.RWMutex
+	creds    Credentials
+	provider SecretProvider
+}
+
+func (s *credentialStore) get() Credentials {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.creds
+}
+
+func (s *credentialStore) set(creds Credentials) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.creds = creds
+}
+
+// NewWithProvider returns a new Api using credentials from a SecretProvider.
+// Calling RefreshCredentials later fetches them from the provider again.
+func NewWithProvider(live bool, provider SecretProvider) (*Api, error) {
+
+	creds, err := provider.Credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	api := New(live, creds.Key, creds.Secret)
+	api.creds.provider = provider
+
+	return api, nil
+}
+
+// SetCredentials replaces the key and secret used to sign requests. Requests
+// already in flight are unaffected.
+func (api *Api) SetCredentials(creds Credentials) {
+	api.creds.set(creds)
+}
+
+// RefreshCredentials fetches credentials from the SecretProvider given to
+// NewWithProvider and starts using them.
+func (api *Api) RefreshCredentials() error {
+
+	if api.creds.provider == nil {
+		return fmt.Errorf("no secret provider configured")
+	}
+
+	creds, err := api.creds.provider.Credentials()
+	if err != nil {
+		return err
+	}
+
+	api.creds.set(creds)
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package gemini
+
+import "strings"
+
+// FeeSchedule holds the fee rates, in basis points, that apply to orders
+// placed through the api.
+type FeeSchedule struct {
+	MakerBps   float64
+	TakerBps   float64
+	AuctionBps float64
+}
+
+// FeeSchedule returns the api fee rates from the notional volume.
+func (v NotionalVolume) FeeSchedule() FeeSchedule {
+	return FeeSchedule{
+		MakerBps:   v.ApiMakerFeeBps,
+		TakerBps:   v.ApiTakerFeeBps,
+		AuctionBps: v.ApiAuctionFeeBps,
+	}
+}
+
+// Fee returns the expected fee, in the quote currency, for an order of the
+// given notional value. Liquidity is "maker", "taker" or "auction", as in
+// OrderFill. Gemini charges the same rate for buys and sells, so the side of
+// the order does not change the fee.
+func (s FeeSchedule) Fee(liquidity string, notional float64) float64 {
+	var bps float64
+
+	switch strings.ToLower(liquidity) {
+	case "maker":
+		bps = s.MakerBps
+	case "auction":
+		bps = s.AuctionBps
+	default:
+		bps = s.TakerBps
+	}
+
+	return notional * bps / 10000
+}
+
+// FeeSchedule fetches the current api fee rates.
+func (api *Api) FeeSchedule() (FeeSchedule, error) {
+
+	volume, err := api.NotionalVolume()
+	if err != nil {
+		return FeeSchedule{}, err
+	}
+
+	return volume.FeeSchedule(), nil
+}
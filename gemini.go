@@ -2,10 +2,8 @@ package gemini
 
 import (
 	"bytes"
-	"crypto/hmac"
-	"crypto/sha512"
+	"context"
 	"encoding/base64"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -27,20 +25,35 @@ const (
 	AUCTION_URI = "/v1/auction/"
 
 	// authenticated
-	PAST_TRADES_URI    = "/v1/mytrades"
-	TRADE_VOLUME_URI   = "/v1/tradevolume"
-	ACTIVE_ORDERS_URI  = "/v1/orders"
-	ORDER_STATUS_URI   = "/v1/order/status"
-	NEW_ORDER_URI      = "/v1/order/new"
-	CANCEL_ORDER_URI   = "/v1/order/cancel"
-	CANCEL_ALL_URI     = "/v1/order/cancel/all"
-	CANCEL_SESSION_URI = "/v1/order/cancel/session"
-	HEARTBEAT_URI      = "/v1/heartbeat"
+	PAST_TRADES_URI     = "/v1/mytrades"
+	TRADE_VOLUME_URI    = "/v1/tradevolume"
+	NOTIONAL_VOLUME_URI = "/v1/notionalvolume"
+	ACTIVE_ORDERS_URI   = "/v1/orders"
+	ORDER_HISTORY_URI   = "/v1/orders/history"
+	ORDER_STATUS_URI    = "/v1/order/status"
+	NEW_ORDER_URI       = "/v1/order/new"
+	CANCEL_ORDER_URI    = "/v1/order/cancel"
+	CANCEL_ALL_URI      = "/v1/order/cancel/all"
+	CANCEL_SESSION_URI  = "/v1/order/cancel/session"
+	HEARTBEAT_URI       = "/v1/heartbeat"
+
+	// accounts
+	ACCOUNT_DETAIL_URI   = "/v1/account"
+	ROLES_URI            = "/v1/roles"
+	LIST_ACCOUNTS_URI    = "/v1/account/list"
+	CREATE_ACCOUNT_URI   = "/v1/account/create"
+	ACCOUNT_TRANSFER_URI = "/v1/account/transfer/"
 
 	// fund mgmt
 	BALANCES_URI            = "/v1/balances"
+	NOTIONAL_BALANCES_URI   = "/v1/notionalbalances/"
+	STAKING_BALANCES_URI    = "/v1/balances/staking"
+	EARN_BALANCES_URI       = "/v1/balances/earn"
 	NEW_DEPOSIT_ADDRESS_URI = "/v1/deposit/"
+	DEPOSIT_ADDRESSES_URI   = "/v1/addresses/"
 	WITHDRAW_FUNDS_URI      = "/v1/withdraw/"
+	TRANSFERS_URI           = "/v1/transfers"
+	APPROVED_ADDRESSES_URI  = "/v1/approvedAddresses/account/"
 
 	// websockets
 	ORDER_EVENTS_URI = "/v1/order/events"
@@ -49,8 +62,22 @@ const (
 
 type Api struct {
 	url    string
-	key    string
-	secret string
+	creds  *credentialStore
+	signer Signer
+
+	// account is sent with every private request when set, to act on a
+	// sub-account using a master account key
+	account string
+
+	// ctx is the context requests are made with, set by WithContext
+	ctx context.Context
+
+	limiter    *RateLimiter
+	logger     Logger
+	observer   Observer
+	tracer     Tracer
+	middleware []Middleware
+	clock      *serverClock
 }
 
 func New(live bool, key, secret string) *Api {
@@ -59,7 +86,25 @@ func New(live bool, key, secret string) *Api {
 		url = BASE_URL
 	}
 
-	return &Api{url: url, key: key, secret: secret}
+	creds := &credentialStore{
+		creds: Credentials{Key: key, Secret: secret},
+	}
+
+	return &Api{
+		url:    url,
+		creds:  creds,
+		signer: &hmacSigner{creds: creds},
+		clock:  &serverClock{threshold: DEFAULT_CLOCK_SKEW_THRESHOLD},
+	}
+}
+
+// Account returns a copy of the Api whose private requests act on the named
+// account. It is used with a master account key to trade and manage funds
+// in its sub-accounts; an empty name acts on the key's own account.
+func (api *Api) Account(name string) *Api {
+	scoped := *api
+	scoped.account = name
+	return &scoped
 }
 
 type ApiError struct {
@@ -112,6 +157,16 @@ type Order struct {
 	RemainingAmount   float64 `json:"remaining_amount,string"`
 	OriginalAmount    float64 `json:"original_amount,string"`
 	AvgExecutionPrice float64 `json:"avg_execution_price,string"`
+	Reason            string  `json:"reason"`
+	Trades            []Trade `json:"trades"`
+}
+
+// OrderStatusRequest looks up an order by either OrderId or ClientOrderId.
+// IncludeTrades returns the trades for the order in Order.Trades.
+type OrderStatusRequest struct {
+	OrderId       string
+	ClientOrderId string
+	IncludeTrades bool
 }
 
 type Trade struct {
@@ -166,6 +221,29 @@ type TradeVolume struct {
 	SellTakerCount    float64 `json:"sell_taker_count"`
 }
 
+type NotionalVolume struct {
+	Date              string                `json:"date"`
+	LastUpdated       int64                 `json:"last_updated_ms"`
+	WebMakerFeeBps    float64               `json:"web_maker_fee_bps"`
+	WebTakerFeeBps    float64               `json:"web_taker_fee_bps"`
+	WebAuctionFeeBps  float64               `json:"web_auction_fee_bps"`
+	ApiMakerFeeBps    float64               `json:"api_maker_fee_bps"`
+	ApiTakerFeeBps    float64               `json:"api_taker_fee_bps"`
+	ApiAuctionFeeBps  float64               `json:"api_auction_fee_bps"`
+	FixMakerFeeBps    float64               `json:"fix_maker_fee_bps"`
+	FixTakerFeeBps    float64               `json:"fix_taker_fee_bps"`
+	FixAuctionFeeBps  float64               `json:"fix_auction_fee_bps"`
+	BlockMakerFeeBps  float64               `json:"block_maker_fee_bps"`
+	BlockTakerFeeBps  float64               `json:"block_taker_fee_bps"`
+	Notional30dVolume float64               `json:"notional_30d_volume"`
+	Notional1dVolume  []DailyNotionalVolume `json:"notional_1d_volume"`
+}
+
+type DailyNotionalVolume struct {
+	Date           string  `json:"date"`
+	NotionalVolume float64 `json:"notional_volume"`
+}
+
 type CurrentAuction struct {
 	ClosedUntil                  int64   `json:"closed_until_ms"`
 	LastAuctionEid               Id      `json:"last_auction_eid"`
@@ -203,6 +281,43 @@ type CancelResultDetails struct {
 	CancelRejects   []Id
 }
 
+type Account struct {
+	Name           string `json:"name"`
+	Account        string `json:"account"`
+	Type           string `json:"type"`
+	CounterpartyId string `json:"counterparty_id"`
+	CreatedAt      int64  `json:"createdAt"`
+}
+
+type AccountDetail struct {
+	Account           AccountInfo   `json:"account"`
+	Users             []AccountUser `json:"users"`
+	MemoReferenceCode string        `json:"memo_reference_code"`
+}
+
+type AccountInfo struct {
+	AccountName string `json:"accountName"`
+	ShortName   string `json:"shortName"`
+	Type        string `json:"type"`
+	Created     int64  `json:"created,string"`
+}
+
+type AccountUser struct {
+	Name        string `json:"name"`
+	LastSignIn  string `json:"lastSignIn"`
+	Status      string `json:"status"`
+	CountryCode string `json:"countryCode"`
+	IsVerified  bool   `json:"isVerified"`
+}
+
+type AccountTransferResult struct {
+	FromAccount string  `json:"fromAccount"`
+	ToAccount   string  `json:"toAccount"`
+	Amount      float64 `json:"amount,string"`
+	Currency    string  `json:"currency"`
+	Uuid        string  `json:"uuid"`
+}
+
 type FundBalance struct {
 	Type                   string  `json:"type"`
 	Currency               string  `json:"currency"`
@@ -211,16 +326,98 @@ type FundBalance struct {
 	AvailableForWithdrawal float64 `json:"availableForWithdrawal,string"`
 }
 
+type NotionalBalance struct {
+	Currency                       string  `json:"currency"`
+	Amount                         float64 `json:"amount,string"`
+	AmountNotional                 float64 `json:"amountNotional,string"`
+	Available                      float64 `json:"available,string"`
+	AvailableNotional              float64 `json:"availableNotional,string"`
+	AvailableForWithdrawal         float64 `json:"availableForWithdrawal,string"`
+	AvailableForWithdrawalNotional float64 `json:"availableForWithdrawalNotional,string"`
+}
+
+// ProviderBalance is used for both staking and earn balances.
+type ProviderBalance struct {
+	Type                   string                          `json:"type"`
+	Currency               string                          `json:"currency"`
+	Balance                float64                         `json:"balance"`
+	Available              float64                         `json:"available"`
+	AvailableForWithdrawal float64                         `json:"availableForWithdrawal"`
+	BalanceByProvider      map[string]ProviderBalanceEntry `json:"balanceByProvider"`
+}
+
+type ProviderBalanceEntry struct {
+	Balance float64 `json:"balance"`
+}
+
 type DepositAddress struct {
-	Currency string `json:"currency"`
-	Address  string `json:"address"`
-	Label    string `json:"label"`
+	Currency  string `json:"currency"`
+	Address   string `json:"address"`
+	Label     string `json:"label"`
+	Timestamp int64  `json:"timestamp"`
 }
 
 type WithdrawFundsResult struct {
-	Destination string  `json:"destination"`
-	TxHash      string  `json:"txHash"`
+	Destination  string  `json:"destination"`
+	TxHash       string  `json:"txHash"`
+	Amount       float64 `json:"amount,string"`
+	WithdrawalId string  `json:"withdrawalId"`
+	Message      string  `json:"message"`
+
+	// Fee is the estimate fetched by Withdraw before the withdrawal was sent
+	Fee WithdrawalFeeEstimate `json:"-"`
+}
+
+// WithdrawRequest holds the parameters for Withdraw. ClientTransferId, Memo
+// and Network are optional; Memo carries the memo or destination tag for
+// currencies that require one, and Network selects the chain for tokens
+// available on more than one. Fee, if set, is a fee estimate already fetched
+// for the withdrawal, which Withdraw then uses instead of fetching another.
+type WithdrawRequest struct {
+	Currency         string                 `json:"currency"`
+	Address          string                 `json:"address"`
+	Amount           float64                `json:"amount"`
+	ClientTransferId string                 `json:"client_transfer_id,omitempty"`
+	Memo             string                 `json:"memo,omitempty"`
+	Network          string                 `json:"network,omitempty"`
+	Fee              *WithdrawalFeeEstimate `json:"-"`
+}
+
+type WithdrawalFee struct {
+	Currency string  `json:"currency"`
+	Value    float64 `json:"value,string"`
+}
+
+type WithdrawalFeeEstimate struct {
+	Currency         string        `json:"currency"`
+	Fee              WithdrawalFee `json:"fee"`
+	IsOverride       bool          `json:"isOverride"`
+	MonthlyLimit     int           `json:"monthlyLimit"`
+	MonthlyRemaining int           `json:"monthlyRemaining"`
+}
+
+type ApprovedAddress struct {
+	Network   string `json:"network"`
+	Scope     string `json:"scope"`
+	Label     string `json:"label"`
+	Status    string `json:"status"`
+	CreatedAt int64  `json:"createdAt,string"`
+	Address   string `json:"address"`
+}
+
+type Transfer struct {
+	Type        string  `json:"type"`
+	Status      string  `json:"status"`
+	Timestamp   int64   `json:"timestampms"`
+	Eid         Id      `json:"eid"`
+	AdvanceEid  Id      `json:"advanceEid"`
+	Currency    string  `json:"currency"`
 	Amount      float64 `json:"amount,string"`
+	Method      string  `json:"method"`
+	TxHash      string  `json:"txHash"`
+	OutputIdx   int     `json:"outputIdx"`
+	Destination string  `json:"destination"`
+	Purpose     string  `json:"purpose"`
 }
 
 // Nonce returns a generic nonce based on unix timestamp
@@ -228,44 +425,85 @@ func Nonce() int64 {
 	return time.Now().UnixNano()
 }
 
-// BuildHeader handles the conversion of post parameters into headers formatted
-// according to Gemini specification. Resulting headers include the API key,
-// the payload and the signature.
+// BuildHeader is SignHeader without the error, kept for compatibility. If the
+// Signer fails the header is empty.
 func (api *Api) BuildHeader(req *map[string]interface{}) http.Header {
+	header, _ := api.SignHeader(req)
+	if header == nil {
+		return http.Header{}
+	}
+	return header
+}
+
+// SignHeader handles the conversion of post parameters into headers formatted
+// according to Gemini specification. Resulting headers include the API key,
+// the payload and the signature, which are provided by the Api's Signer,
+// whose error is returned if signing fails.
+func (api *Api) SignHeader(req *map[string]interface{}) (http.Header, error) {
 
 	reqStr, _ := json.Marshal(req)
 	payload := base64.StdEncoding.EncodeToString([]byte(reqStr))
 
-	mac := hmac.New(sha512.New384, []byte(api.secret))
-	mac.Write([]byte(payload))
-
-	signature := hex.EncodeToString(mac.Sum(nil))
+	key, signature, err := api.signer.Sign(payload)
+	if err != nil {
+		return nil, err
+	}
 
 	header := http.Header{}
-	header.Set("X-GEMINI-APIKEY", api.key)
+	header.Set("X-GEMINI-APIKEY", key)
 	header.Set("X-GEMINI-PAYLOAD", payload)
 	header.Set("X-GEMINI-SIGNATURE", signature)
 
-	return header
+	return header, nil
 }
 
-// request makes the HTTP request to Gemini and handles any returned errors
+// request makes the HTTP request to Gemini through the middleware chain and
+// handles any returned errors
 func (api *Api) request(verb, url string, params map[string]interface{}) ([]byte, error) {
 
-	req, err := http.NewRequest(verb, url, bytes.NewBuffer([]byte{}))
+	req := &Request{
+		RequestInfo: requestInfo(verb, url, params),
+		URL:         url,
+		Context:     api.context(),
+	}
+
+	resp, err := api.handler()(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// send is the last Handler in the chain. It signs and sends the request and
+// checks the response for an error from Gemini.
+func (api *Api) send(r *Request) (*Response, error) {
+
+	req, err := http.NewRequestWithContext(r.Context, r.Method, r.URL, bytes.NewBuffer([]byte{}))
 	if err != nil {
 		return nil, err
 	}
 
+	params := r.Params
 	if params != nil {
-		if verb == "GET" {
+		if r.Method == "GET" {
 			q := req.URL.Query()
 			for key, val := range params {
 				q.Add(key, val.(string))
 			}
 			req.URL.RawQuery = q.Encode()
 		} else {
-			req.Header = api.BuildHeader(&params)
+			if _, ok := params["account"]; !ok && api.account != "" {
+				params["account"] = api.account
+			}
+			// the nonce is set at signing so that it increases in the order
+			// requests are sent and every attempt gets a new one
+			params["nonce"] = Nonce()
+			req.Header, err = api.SignHeader(&params)
+			if err != nil {
+				return nil, err
+			}
+			r.Header = req.Header
 		}
 	}
 
@@ -276,19 +514,24 @@ func (api *Api) request(verb, url string, params map[string]interface{}) ([]byte
 	}
 	defer resp.Body.Close()
 
+	res := &Response{
+		Status: resp.StatusCode,
+		Header: resp.Header,
+	}
+
 	// read response body
-	body, err := ioutil.ReadAll(resp.Body)
+	res.Body, err = ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return res, err
 	}
 
 	// check for error from Gemini
-	var res GenericResponse
+	var gr GenericResponse
 
-	json.Unmarshal(body, &res)
-	if res.Result == "error" {
-		return nil, &res.ApiError
+	json.Unmarshal(res.Body, &gr)
+	if gr.Result == "error" {
+		return res, &gr.ApiError
 	}
 
-	return body, nil
+	return res, nil
 }
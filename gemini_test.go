@@ -0,0 +1,59 @@
+package gemini
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fakeHandler answers a request to the fake exchange with the decoded
+// payload parameters, returning the HTTP status and a value to encode as the
+// JSON response.
+type fakeHandler func(params map[string]interface{}) (int, interface{})
+
+// fakeGemini starts a server answering requests by path with the given
+// handlers, one at a time, and returns an Api using it.
+func fakeGemini(t *testing.T, handlers map[string]fakeHandler) *Api {
+
+	var mu sync.Mutex
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		handler, ok := handlers[r.URL.Path]
+		if !ok {
+			t.Errorf("unexpected request to %v", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+
+		params := make(map[string]interface{})
+		if payload := r.Header.Get("X-GEMINI-PAYLOAD"); payload != "" {
+			b, _ := base64.StdEncoding.DecodeString(payload)
+			json.Unmarshal(b, &params)
+		}
+
+		status, res := handler(params)
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(res)
+	}))
+	t.Cleanup(srv.Close)
+
+	api := New(false, "key", "secret")
+	api.url = srv.URL
+
+	return api
+}
+
+func apiError(reason, message string) (int, interface{}) {
+	return http.StatusBadRequest, map[string]string{
+		"result":  "error",
+		"reason":  reason,
+		"message": message,
+	}
+}
@@ -0,0 +1,208 @@
+package gemini
+
+import "sort"
+
+// TransferIterator pages through transfers oldest first, starting at a
+// timestamp. Each page is requested from the timestamp of the newest
+// transfer already seen; transfers repeated across the page boundary are
+// skipped by eid.
+//
+//	it := api.TransferIterator(since, 50)
+//	for it.Next() {
+//		transfer := it.Transfer()
+//		...
+//	}
+//	err := it.Err()
+type TransferIterator struct {
+	api   *Api
+	since int64
+	limit int
+
+	page []Transfer
+	cur  Transfer
+	seen map[Id]bool
+	last bool
+	err  error
+}
+
+func (api *Api) TransferIterator(since int64, limit int) *TransferIterator {
+	if limit <= 0 {
+		limit = 50
+	}
+	return &TransferIterator{
+		api:   api,
+		since: since,
+		limit: limit,
+		seen:  make(map[Id]bool),
+	}
+}
+
+// Next advances to the next transfer, fetching another page when needed. It
+// returns false when there are no more transfers or an error occurred.
+func (it *TransferIterator) Next() bool {
+
+	for len(it.page) == 0 {
+		if it.last || it.err != nil {
+			return false
+		}
+		it.fetch()
+	}
+
+	it.cur, it.page = it.page[0], it.page[1:]
+
+	return true
+}
+
+// Transfer returns the current transfer.
+func (it *TransferIterator) Transfer() Transfer {
+	return it.cur
+}
+
+// Err returns the error, if any, that stopped the iteration.
+func (it *TransferIterator) Err() error {
+	return it.err
+}
+
+func (it *TransferIterator) fetch() {
+
+	transfers, err := it.api.Transfers(it.since, it.limit)
+	if err != nil {
+		it.err = err
+		return
+	}
+
+	if len(transfers) < it.limit {
+		it.last = true
+	}
+
+	sort.Slice(transfers, func(i, j int) bool {
+		return transfers[i].Timestamp < transfers[j].Timestamp
+	})
+
+	var fresh []Transfer
+	for _, transfer := range transfers {
+		if it.seen[transfer.Eid] {
+			continue
+		}
+		it.seen[transfer.Eid] = true
+		fresh = append(fresh, transfer)
+
+		if transfer.Timestamp > it.since {
+			it.since = transfer.Timestamp
+		}
+	}
+
+	// a full page of transfers that have all been seen cannot advance
+	if len(fresh) == 0 {
+		it.last = true
+	}
+
+	it.page = fresh
+}
+
+// FindTransfer returns the first transfer since the given timestamp with the
+// given transaction hash, such as the TxHash of a WithdrawFundsResult.
+func (api *Api) FindTransfer(txHash string, since int64) (Transfer, bool, error) {
+
+	it := api.TransferIterator(since, 50)
+	for it.Next() {
+		if transfer := it.Transfer(); transfer.TxHash == txHash {
+			return transfer, true, nil
+		}
+	}
+
+	return Transfer{}, false, it.Err()
+}
+
+// OrderHistoryIterator pages through closed and cancelled orders oldest
+// first, starting at a timestamp, in the same way as TransferIterator.
+// Orders repeated across the page boundary are skipped by order id.
+type OrderHistoryIterator struct {
+	api    *Api
+	symbol string
+	since  int64
+	limit  int
+
+	page []Order
+	cur  Order
+	seen map[Id]bool
+	last bool
+	err  error
+}
+
+// OrderHistoryIterator returns an iterator over past orders for a symbol, or
+// for all symbols if symbol is empty.
+func (api *Api) OrderHistoryIterator(symbol string, since int64, limit int) *OrderHistoryIterator {
+	if limit <= 0 {
+		limit = 500
+	}
+	return &OrderHistoryIterator{
+		api:    api,
+		symbol: symbol,
+		since:  since,
+		limit:  limit,
+		seen:   make(map[Id]bool),
+	}
+}
+
+// Next advances to the next order, fetching another page when needed. It
+// returns false when there are no more orders or an error occurred.
+func (it *OrderHistoryIterator) Next() bool {
+
+	for len(it.page) == 0 {
+		if it.last || it.err != nil {
+			return false
+		}
+		it.fetch()
+	}
+
+	it.cur, it.page = it.page[0], it.page[1:]
+
+	return true
+}
+
+// Order returns the current order.
+func (it *OrderHistoryIterator) Order() Order {
+	return it.cur
+}
+
+// Err returns the error, if any, that stopped the iteration.
+func (it *OrderHistoryIterator) Err() error {
+	return it.err
+}
+
+func (it *OrderHistoryIterator) fetch() {
+
+	orders, err := it.api.OrderHistory(it.symbol, it.since, it.limit)
+	if err != nil {
+		it.err = err
+		return
+	}
+
+	if len(orders) < it.limit {
+		it.last = true
+	}
+
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].Timestamp < orders[j].Timestamp
+	})
+
+	var fresh []Order
+	for _, order := range orders {
+		if it.seen[order.OrderId] {
+			continue
+		}
+		it.seen[order.OrderId] = true
+		fresh = append(fresh, order)
+
+		if order.Timestamp > it.since {
+			it.since = order.Timestamp
+		}
+	}
+
+	if len(fresh) == 0 {
+		it.last = true
+	}
+
+	it.page = fresh
+}
@@ -0,0 +1,138 @@
+package gemini
+
+import (
+	"fmt"
+	"sync"
+)
+
+const KILL_SWITCH_RETRIES = 3
+
+type HaltError struct {
+	Reason string
+}
+
+func (e *HaltError) Error() string {
+	return fmt.Sprintf("[Halted] order entry halted: %v", e.Reason)
+}
+
+// KillSwitchReport lists the orders cancelled and rejected while the switch
+// was triggered. Errors holds any failed request that was retried past.
+type KillSwitchReport struct {
+	Reason    string
+	Details   CancelResultDetails
+	Remaining []Order
+	Errors    []error
+}
+
+type haltState struct {
+	mu     sync.RWMutex
+	halted bool
+	reason string
+}
+
+// halt is the order entry state of the whole process.
+var halt haltState
+
+// KillSwitch stops trading. Halting is process-wide: once any KillSwitch is
+// triggered, every NewOrder call on every Api in the process, however it was
+// created, returns a *HaltError until Reset is called on any KillSwitch.
+// Trigger cancels orders only through the Api the KillSwitch was created
+// with, so to cancel the orders of several accounts, such as those of an
+// AccountPool, create and trigger a KillSwitch for each.
+type KillSwitch struct {
+	api   *Api
+	state *haltState
+}
+
+// NewKillSwitch returns a KillSwitch that cancels orders through the given
+// Api.
+func NewKillSwitch(api *Api) *KillSwitch {
+	return &KillSwitch{api: api, state: &halt}
+}
+
+// Trigger halts order entry, cancels all orders and verifies with
+// ActiveOrders that none remain, retrying any stragglers individually with
+// CancelOrder. A failed CancelAll or ActiveOrders is recorded in the report
+// and the remaining steps still run. The report lists every cancelled and
+// rejected order id seen, and any orders still live after the final retry.
+func (ks *KillSwitch) Trigger(reason string) (KillSwitchReport, error) {
+
+	ks.state.mu.Lock()
+	ks.state.halted = true
+	ks.state.reason = reason
+	ks.state.mu.Unlock()
+
+	report := KillSwitchReport{Reason: reason}
+
+	res, err := ks.api.CancelAll()
+	if err != nil {
+		report.Errors = append(report.Errors, err)
+	} else {
+		report.Details.CancelledOrders = append(report.Details.CancelledOrders, res.Details.CancelledOrders...)
+		report.Details.CancelRejects = append(report.Details.CancelRejects, res.Details.CancelRejects...)
+	}
+
+	for i := 0; i < KILL_SWITCH_RETRIES; i++ {
+
+		orders, err := ks.api.ActiveOrders()
+		if err != nil {
+			report.Errors = append(report.Errors, err)
+			continue
+		}
+
+		report.Remaining = orders
+		if len(orders) == 0 {
+			return report, nil
+		}
+
+		for _, order := range orders {
+			if _, err := ks.api.CancelOrder(string(order.OrderId)); err != nil {
+				report.Errors = append(report.Errors, err)
+				report.Details.CancelRejects = append(report.Details.CancelRejects, order.OrderId)
+				continue
+			}
+			report.Details.CancelledOrders = append(report.Details.CancelledOrders, order.OrderId)
+		}
+	}
+
+	orders, err := ks.api.ActiveOrders()
+	if err != nil {
+		return report, err
+	}
+
+	report.Remaining = orders
+	if len(orders) > 0 {
+		return report, fmt.Errorf("%d orders still active after kill switch", len(orders))
+	}
+
+	return report, nil
+}
+
+// Reset allows order entry to resume.
+func (ks *KillSwitch) Reset() {
+	ks.state.mu.Lock()
+	defer ks.state.mu.Unlock()
+
+	ks.state.halted = false
+	ks.state.reason = ""
+}
+
+// Halted reports whether order entry is halted and why.
+func (ks *KillSwitch) Halted() (bool, string) {
+	return ks.state.get()
+}
+
+func (s *haltState) get() (bool, string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.halted, s.reason
+}
+
+// check returns a *HaltError if order entry has been halted.
+func (s *haltState) check() error {
+	if halted, reason := s.get(); halted {
+		return &HaltError{Reason: reason}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package gemini
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Logger receives a record of every request made through the Api. It is
+// satisfied by *slog.Logger.
+type Logger interface {
+	Log(ctx context.Context, level slog.Level, msg string, args ...interface{})
+}
+
+// headers that carry credentials or signed request contents
+var redactedHeaders = []string{
+	"X-GEMINI-APIKEY",
+	"X-GEMINI-PAYLOAD",
+	"X-GEMINI-SIGNATURE",
+}
+
+// SetLogger sets the Logger that records each request. Successful requests
+// are logged at info, Gemini errors at warn and transport errors at error;
+// request headers, with credentials redacted, are added at debug.
+func (api *Api) SetLogger(logger Logger) {
+	api.logger = logger
+}
+
+// Logger returns the Api's Logger, or nil if none is set.
+func (api *Api) Logger() Logger {
+	return api.logger
+}
+
+// LogStreamConnect records that a websocket stream has connected, so that
+// code handling streams logs through the same Logger as requests. Like
+// LogStreamDisconnect and LogStreamFrame it does nothing without a Logger.
+func (api *Api) LogStreamConnect(rawurl string) {
+	if api.logger == nil {
+		return
+	}
+	api.logger.Log(api.context(), slog.LevelInfo, "gemini stream connected",
+		api.streamArgs(rawurl)...)
+}
+
+// LogStreamDisconnect records that a websocket stream has ended, at info if
+// it was closed cleanly with a nil err and at warn otherwise.
+func (api *Api) LogStreamDisconnect(rawurl string, err error) {
+	if api.logger == nil {
+		return
+	}
+
+	args := api.streamArgs(rawurl)
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelWarn
+		args = append(args, slog.String("error", err.Error()))
+	}
+
+	api.logger.Log(api.context(), level, "gemini stream disconnected", args...)
+}
+
+// LogStreamFrame records a message received on a websocket stream at debug.
+func (api *Api) LogStreamFrame(rawurl string, frame []byte) {
+	if api.logger == nil {
+		return
+	}
+	api.logger.Log(api.context(), slog.LevelDebug, "gemini stream frame",
+		append(api.streamArgs(rawurl), slog.String("frame", string(frame)))...)
+}
+
+func (api *Api) streamArgs(rawurl string) []interface{} {
+	args := []interface{}{slog.String("endpoint", endpoint(rawurl))}
+	if api.account != "" {
+		args = append(args, slog.String("account", api.account))
+	}
+	return args
+}
+
+// endpoint returns the path of a url, or the url itself if it cannot be
+// parsed.
+func endpoint(rawurl string) string {
+	if u, err := url.Parse(rawurl); err == nil {
+		return u.Path
+	}
+	return rawurl
+}
+
+// RedactHeader returns a copy of the header with the api key, payload and
+// signature replaced.
+func RedactHeader(header http.Header) http.Header {
+	redacted := header.Clone()
+	for _, name := range redactedHeaders {
+		if redacted.Get(name) != "" {
+			redacted.Set(name, "[REDACTED]")
+		}
+	}
+	return redacted
+}
+
+func (api *Api) logRequest(verb, rawurl string, header http.Header, status int, latency time.Duration, err error) {
+
+	endpoint := endpoint(rawurl)
+
+	args := []interface{}{
+		slog.String("method", verb),
+		slog.String("endpoint", endpoint),
+		slog.Duration("latency", latency),
+		slog.Int("status", status),
+	}
+
+	if api.account != "" {
+		args = append(args, slog.String("account", api.account))
+	}
+
+	ctx := api.context()
+	level := slog.LevelInfo
+
+	switch e := err.(type) {
+	case nil:
+	case *ApiError:
+		level = slog.LevelWarn
+		args = append(args, slog.String("reason", e.Reason), slog.String("message", e.Message))
+	default:
+		level = slog.LevelError
+		args = append(args, slog.String("error", err.Error()))
+	}
+
+	api.logger.Log(ctx, level, "gemini request", args...)
+
+	if header != nil {
+		api.logger.Log(ctx, slog.LevelDebug, "gemini request headers",
+			slog.String("endpoint", endpoint),
+			slog.Any("headers", RedactHeader(header)))
+	}
+}
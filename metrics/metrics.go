@@ -0,0 +1,127 @@
+// Package metrics exports Prometheus metrics for the gemini package.
+//
+//	m := metrics.New(prometheus.DefaultRegisterer)
+//	api.SetObserver(m)
+//
+// REST requests are measured through the Api's Observer hook. Websocket
+// streams are handled outside the gemini package, so their health is
+// recorded by calling Reconnect, SequenceGap, Message and BookLag from the
+// stream handling code.
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "gemini"
+
+type Metrics struct {
+	requests      *prometheus.CounterVec
+	latency       *prometheus.HistogramVec
+	rateLimitWait prometheus.Histogram
+	reconnects    *prometheus.CounterVec
+	sequenceGaps  *prometheus.CounterVec
+	messages      *prometheus.CounterVec
+	bookLag       *prometheus.HistogramVec
+}
+
+// New creates the metrics and registers them with reg.
+func New(reg prometheus.Registerer) *Metrics {
+
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_total",
+			Help:      "REST requests by endpoint, HTTP status and Gemini error reason.",
+		}, []string{"endpoint", "status", "reason"}),
+
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_duration_seconds",
+			Help:      "REST request latency by endpoint.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"endpoint"}),
+
+		rateLimitWait: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "rate_limit_wait_seconds",
+			Help:      "Time requests spent waiting on the rate limiter.",
+			Buckets:   []float64{0, .01, .05, .1, .25, .5, 1, 2.5, 5},
+		}),
+
+		reconnects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "stream_reconnects_total",
+			Help:      "Websocket reconnects by stream.",
+		}, []string{"stream"}),
+
+		sequenceGaps: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "stream_sequence_gaps_total",
+			Help:      "Messages missed according to stream sequence numbers.",
+		}, []string{"stream"}),
+
+		messages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "stream_messages_total",
+			Help:      "Websocket messages by symbol and event type.",
+		}, []string{"symbol", "type"}),
+
+		bookLag: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "book_update_lag_seconds",
+			Help:      "Time between an order book event and it being applied locally.",
+			Buckets:   []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 5},
+		}, []string{"symbol"}),
+	}
+
+	reg.MustRegister(
+		m.requests,
+		m.latency,
+		m.rateLimitWait,
+		m.reconnects,
+		m.sequenceGaps,
+		m.messages,
+		m.bookLag,
+	)
+
+	return m
+}
+
+// ObserveRequest implements gemini.Observer.
+func (m *Metrics) ObserveRequest(endpoint string, status int, reason string, latency time.Duration) {
+	m.requests.WithLabelValues(endpoint, strconv.Itoa(status), reason).Inc()
+	m.latency.WithLabelValues(endpoint).Observe(latency.Seconds())
+}
+
+// ObserveRateLimitWait implements gemini.Observer.
+func (m *Metrics) ObserveRateLimitWait(wait time.Duration) {
+	m.rateLimitWait.Observe(wait.Seconds())
+}
+
+// Reconnect records a reconnect of the named stream, such as "marketdata"
+// or "order_events".
+func (m *Metrics) Reconnect(stream string) {
+	m.reconnects.WithLabelValues(stream).Inc()
+}
+
+// SequenceGap records the number of messages missed on a stream, as found
+// from a jump in its sequence numbers.
+func (m *Metrics) SequenceGap(stream string, missed int) {
+	m.sequenceGaps.WithLabelValues(stream).Add(float64(missed))
+}
+
+// Message records a message received for a symbol.
+func (m *Metrics) Message(symbol, eventType string) {
+	m.messages.WithLabelValues(symbol, eventType).Inc()
+}
+
+// BookLag records how far behind the exchange an order book update was when
+// it was applied, given the event's timestampms.
+func (m *Metrics) BookLag(symbol string, timestampms int64) {
+	lag := time.Since(time.Unix(0, timestampms*int64(time.Millisecond)))
+	m.bookLag.WithLabelValues(symbol).Observe(lag.Seconds())
+}
@@ -0,0 +1,125 @@
+package gemini
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Request describes a request passing through the middleware chain. A
+// middleware may change Params or Context before calling the next Handler;
+// Header holds the signed request headers once the request has been sent.
+// The nonce is added to Params only when the request is signed, so
+// middleware must not rely on it, and a middleware that calls next again to
+// retry sends a fresh one.
+type Request struct {
+	RequestInfo
+	URL     string
+	Context context.Context
+	Header  http.Header
+}
+
+// Response is the result of a request. It is returned alongside an
+// *ApiError when Gemini responds with an error.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+type Handler func(req *Request) (*Response, error)
+
+// Middleware wraps a Handler to add behaviour to every request.
+//
+//	api.Use(func(next gemini.Handler) gemini.Handler {
+//		return func(req *gemini.Request) (*gemini.Response, error) {
+//			audit(req.Endpoint, req.Params)
+//			return next(req)
+//		}
+//	})
+type Middleware func(next Handler) Handler
+
+// Use appends middleware to the chain. The first middleware added is the
+// outermost. The Logger, RateLimiter, Observer and Tracer set on the Api run
+// inside all middleware added with Use, in that order, followed by the clock
+// skew estimate, before the request is signed and sent. The Observer runs
+// inside the RateLimiter so that the latency it records excludes the wait.
+func (api *Api) Use(middleware ...Middleware) {
+	// copy so that Apis returned from Account and WithContext do not share
+	// later additions
+	api.middleware = append(api.middleware[:len(api.middleware):len(api.middleware)], middleware...)
+}
+
+// handler builds the middleware chain for a request.
+func (api *Api) handler() Handler {
+
+	h := api.clockMiddleware(api.send)
+
+	if api.tracer != nil {
+		h = api.traceMiddleware(h)
+	}
+	if api.observer != nil {
+		h = api.observeMiddleware(h)
+	}
+	if api.limiter != nil {
+		h = api.limitMiddleware(h)
+	}
+	if api.logger != nil {
+		h = api.logMiddleware(h)
+	}
+
+	for i := len(api.middleware) - 1; i >= 0; i-- {
+		h = api.middleware[i](h)
+	}
+
+	return h
+}
+
+func (api *Api) logMiddleware(next Handler) Handler {
+	return func(req *Request) (*Response, error) {
+		start := time.Now()
+		resp, err := next(req)
+		api.logRequest(req.Method, req.URL, req.Header, status(resp), time.Since(start), err)
+		return resp, err
+	}
+}
+
+func (api *Api) observeMiddleware(next Handler) Handler {
+	return func(req *Request) (*Response, error) {
+		start := time.Now()
+		resp, err := next(req)
+		api.observeRequest(req.URL, status(resp), time.Since(start), err)
+		return resp, err
+	}
+}
+
+func (api *Api) limitMiddleware(next Handler) Handler {
+	return func(req *Request) (*Response, error) {
+		// public endpoints do not count against the private request limit
+		if req.Method == "GET" {
+			return next(req)
+		}
+		wait := api.limiter.Wait()
+		if api.observer != nil {
+			api.observer.ObserveRateLimitWait(wait)
+		}
+		return next(req)
+	}
+}
+
+func (api *Api) traceMiddleware(next Handler) Handler {
+	return func(req *Request) (*Response, error) {
+		ctx, end := api.tracer.StartRequest(req.Context, req.RequestInfo)
+		req.Context = ctx
+		resp, err := next(req)
+		end(status(resp), err)
+		return resp, err
+	}
+}
+
+func status(resp *Response) int {
+	if resp == nil {
+		return 0
+	}
+	return resp.Status
+}
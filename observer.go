@@ -0,0 +1,43 @@
+package gemini
+
+import (
+	"net/url"
+	"time"
+)
+
+// Observer receives measurements of requests made through the Api, for
+// exporting as metrics. See the metrics subpackage for a Prometheus
+// implementation.
+type Observer interface {
+	// ObserveRequest is called after every request with the endpoint path,
+	// the HTTP status (0 if no response was received), the ApiError reason
+	// if Gemini returned an error, and the time taken, not counting any
+	// wait on the RateLimiter.
+	ObserveRequest(endpoint string, status int, reason string, latency time.Duration)
+
+	// ObserveRateLimitWait is called with the time each request spent
+	// waiting on the Api's RateLimiter.
+	ObserveRateLimitWait(wait time.Duration)
+}
+
+// SetObserver sets the Observer that receives request measurements.
+func (api *Api) SetObserver(observer Observer) {
+	api.observer = observer
+}
+
+func (api *Api) observeRequest(rawurl string, status int, latency time.Duration, err error) {
+
+	endpoint := rawurl
+	if u, perr := url.Parse(rawurl); perr == nil {
+		endpoint = u.Path
+	}
+
+	var reason string
+	if e, ok := err.(*ApiError); ok {
+		reason = e.Reason
+	} else if err != nil {
+		reason = "RequestFailed"
+	}
+
+	api.observer.ObserveRequest(endpoint, status, reason, latency)
+}
@@ -0,0 +1,421 @@
+package gemini
+
+import (
+	"fmt"
+	"sync"
+)
+
+type OrderState string
+
+const (
+	OrderPending         OrderState = "pending"
+	OrderAccepted        OrderState = "accepted"
+	OrderBooked          OrderState = "booked"
+	OrderPartiallyFilled OrderState = "partially_filled"
+	OrderFilled          OrderState = "filled"
+	OrderCancelled       OrderState = "cancelled"
+	OrderRejected        OrderState = "rejected"
+	OrderClosed          OrderState = "closed"
+)
+
+// orderTransitions lists the states an order may move to from each state.
+// Snapshots from the REST api can skip intermediate states, so most states
+// allow jumping directly ahead.
+var orderTransitions = map[OrderState][]OrderState{
+	OrderPending:         {OrderAccepted, OrderBooked, OrderPartiallyFilled, OrderFilled, OrderCancelled, OrderRejected, OrderClosed},
+	OrderAccepted:        {OrderBooked, OrderPartiallyFilled, OrderFilled, OrderCancelled, OrderRejected, OrderClosed},
+	OrderBooked:          {OrderPartiallyFilled, OrderFilled, OrderCancelled, OrderClosed},
+	OrderPartiallyFilled: {OrderPartiallyFilled, OrderFilled, OrderCancelled, OrderClosed},
+	OrderFilled:          {OrderClosed},
+	OrderCancelled:       {OrderClosed},
+	OrderRejected:        {OrderClosed},
+	OrderClosed:          {},
+}
+
+// orderStages ranks states by how far through the lifecycle they are, so
+// that a snapshot or event arriving after a later one can be recognised as
+// stale. Terminal states share a stage.
+var orderStages = map[OrderState]int{
+	OrderPending:         0,
+	OrderAccepted:        1,
+	OrderBooked:          2,
+	OrderPartiallyFilled: 3,
+	OrderFilled:          4,
+	OrderCancelled:       4,
+	OrderRejected:        4,
+	OrderClosed:          5,
+}
+
+// CanTransition reports whether an order in state s may move to state next.
+func (s OrderState) CanTransition(next OrderState) bool {
+	for _, state := range orderTransitions[s] {
+		if state == next {
+			return true
+		}
+	}
+	return false
+}
+
+// isStale reports whether an order in state s has already reached the stage
+// of next, so a move to next carries no new information.
+func (s OrderState) isStale(next OrderState) bool {
+	return orderStages[next] <= orderStages[s]
+}
+
+// IsOpen reports whether the state is one in which the order may still trade.
+func (s OrderState) IsOpen() bool {
+	switch s {
+	case OrderPending, OrderAccepted, OrderBooked, OrderPartiallyFilled:
+		return true
+	}
+	return false
+}
+
+type ManagedOrder struct {
+	Order
+	State  OrderState
+	Reason string
+	Fills  []OrderFill
+}
+
+// OrderManager keeps a local record of orders and their lifecycle so that
+// open orders and fills can be queried without a round trip to Gemini. Orders
+// are indexed by both OrderId and ClientOrderId.
+type OrderManager struct {
+	api *Api
+
+	mu       sync.RWMutex
+	orders   map[Id]*ManagedOrder
+	byClient map[string]*ManagedOrder
+}
+
+func NewOrderManager(api *Api) *OrderManager {
+	return &OrderManager{
+		api:      api,
+		orders:   make(map[Id]*ManagedOrder),
+		byClient: make(map[string]*ManagedOrder),
+	}
+}
+
+// NewOrder places an order through the Api and records it.
+func (m *OrderManager) NewOrder(symbol, clientOrderId string, amount, price float64, side string, options []string) (Order, error) {
+
+	order, err := m.api.NewOrder(symbol, clientOrderId, amount, price, side, options)
+	if err != nil {
+		return order, err
+	}
+
+	m.Track(order)
+
+	return order, nil
+}
+
+// Track records an order snapshot, such as one returned from NewOrder,
+// OrderStatus or ActiveOrders. A snapshot older than what is already known,
+// such as a NewOrder response arriving after a fill event, only adds any
+// missing ids; it never moves an order backwards or overwrites newer data.
+func (m *OrderManager) Track(order Order) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mo := m.lookup(order.OrderId, order.ClientOrderId)
+	if mo == nil {
+		mo = &ManagedOrder{State: OrderPending}
+	}
+
+	next := stateFromOrder(order)
+
+	if mo.isNewer(next, order.ExecutedAmount) {
+		mo.Order = order
+		mo.State = next
+	} else {
+		setIds(&mo.Order, order.OrderId, order.ClientOrderId)
+	}
+
+	m.index(mo)
+}
+
+// Apply updates the local record from an order event received over the
+// order events websocket. Events for orders that are not yet tracked, such as
+// those placed by another process, start a new record. Events from a stage
+// the order has already passed, such as an accepted event after the NewOrder
+// response, are ignored apart from recording any fill. An error is returned
+// if the event would move the order into a later state it cannot reach.
+func (m *OrderManager) Apply(ev OrderEvent) error {
+
+	var next OrderState
+
+	switch ev.Type {
+	case "initial", "booked":
+		next = OrderBooked
+	case "accepted":
+		next = OrderAccepted
+	case "fill":
+		next = OrderPartiallyFilled
+		if ev.RemainingAmount == 0 {
+			next = OrderFilled
+		}
+	case "cancelled":
+		next = OrderCancelled
+	case "rejected":
+		next = OrderRejected
+	case "closed":
+		next = OrderClosed
+	default:
+		// subscription_ack, heartbeat, cancel_rejected etc. do not change
+		// the state of an order
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mo := m.lookup(ev.OrderId, ev.ClientOrderId)
+	if mo == nil {
+		mo = &ManagedOrder{State: OrderPending}
+	}
+
+	if !mo.isNewer(next, ev.ExecutedAmount) {
+		if !mo.State.isStale(next) {
+			return fmt.Errorf("order %v: invalid transition from %v to %v", ev.OrderId, mo.State, next)
+		}
+		setIds(&mo.Order, ev.OrderId, ev.ClientOrderId)
+	} else {
+		updateOrderFromEvent(&mo.Order, ev)
+		mo.State = next
+		if ev.Reason != "" {
+			mo.Reason = ev.Reason
+		}
+	}
+
+	m.index(mo)
+
+	if ev.Type == "fill" && !hasFill(mo.Fills, ev.Fill.TradeId) {
+		mo.Fills = append(mo.Fills, ev.Fill)
+	}
+
+	return nil
+}
+
+// Open returns all orders that are still able to trade.
+func (m *OrderManager) Open() []ManagedOrder {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var open []ManagedOrder
+	for _, mo := range m.orders {
+		if mo.State.IsOpen() {
+			open = append(open, *mo)
+		}
+	}
+
+	return open
+}
+
+// Get returns the order with the given order id or client order id.
+func (m *OrderManager) Get(id string) (ManagedOrder, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	mo := m.lookup(Id(id), id)
+	if mo == nil {
+		return ManagedOrder{}, false
+	}
+
+	return *mo, true
+}
+
+// Fills returns the fills recorded for the order with the given order id or
+// client order id.
+func (m *OrderManager) Fills(id string) []OrderFill {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	mo := m.lookup(Id(id), id)
+	if mo == nil {
+		return nil
+	}
+
+	return append([]OrderFill(nil), mo.Fills...)
+}
+
+// Recover looks up an order by client order id, with its trades, and records
+// it along with its fills. It is used when NewOrder fails without a response,
+// such as after a timeout, to find out whether the order was placed.
+func (m *OrderManager) Recover(clientOrderId string) (ManagedOrder, error) {
+
+	order, err := m.api.OrderStatusBy(OrderStatusRequest{
+		ClientOrderId: clientOrderId,
+		IncludeTrades: true,
+	})
+	if err != nil {
+		return ManagedOrder{}, err
+	}
+
+	m.Track(order)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mo := m.lookup(order.OrderId, order.ClientOrderId)
+	if mo == nil {
+		return ManagedOrder{}, fmt.Errorf("order %v not found", clientOrderId)
+	}
+
+	for _, trade := range order.Trades {
+		if !hasFill(mo.Fills, trade.TradeId) {
+			mo.Fills = append(mo.Fills, fillFromTrade(trade))
+		}
+	}
+
+	return *mo, nil
+}
+
+// Reconcile brings the local record in line with Gemini. It should be called
+// on startup and after any gap in the order events stream. Live orders are
+// fetched with ActiveOrders, and any order believed to be open that is no
+// longer live is refreshed with OrderStatus.
+func (m *OrderManager) Reconcile() error {
+
+	active, err := m.api.ActiveOrders()
+	if err != nil {
+		return err
+	}
+
+	live := make(map[Id]bool)
+	for _, order := range active {
+		live[order.OrderId] = true
+		m.Track(order)
+	}
+
+	var stale []Id
+	m.mu.RLock()
+	for id, mo := range m.orders {
+		if mo.State.IsOpen() && !live[id] {
+			stale = append(stale, id)
+		}
+	}
+	m.mu.RUnlock()
+
+	for _, id := range stale {
+		order, err := m.api.OrderStatus(string(id))
+		if err != nil {
+			return err
+		}
+		m.Track(order)
+	}
+
+	return nil
+}
+
+// isNewer reports whether an update moving the order to state next, with the
+// given executed amount, is newer than the local record. Repeated updates in
+// the same state are newer only if no less of the order has been executed.
+func (mo *ManagedOrder) isNewer(next OrderState, executed float64) bool {
+	if next == mo.State {
+		return mo.State.CanTransition(next) && executed >= mo.ExecutedAmount
+	}
+	return mo.State.CanTransition(next)
+}
+
+// lookup finds an order by order id, falling back to client order id. The
+// caller must hold the lock.
+func (m *OrderManager) lookup(orderId Id, clientOrderId string) *ManagedOrder {
+	if mo, ok := m.orders[orderId]; ok && orderId != "" {
+		return mo
+	}
+	if mo, ok := m.byClient[clientOrderId]; ok && clientOrderId != "" {
+		return mo
+	}
+	return nil
+}
+
+// index adds the order to both indexes. The caller must hold the lock.
+func (m *OrderManager) index(mo *ManagedOrder) {
+	if mo.OrderId != "" {
+		m.orders[mo.OrderId] = mo
+	}
+	if mo.ClientOrderId != "" {
+		m.byClient[mo.ClientOrderId] = mo
+	}
+}
+
+// stateFromOrder derives the lifecycle state from an Order snapshot.
+func stateFromOrder(order Order) OrderState {
+	switch {
+	case order.IsCancelled:
+		return OrderCancelled
+	case order.IsLive && order.ExecutedAmount > 0:
+		return OrderPartiallyFilled
+	case order.IsLive:
+		return OrderBooked
+	case order.ExecutedAmount > 0 && order.RemainingAmount == 0:
+		return OrderFilled
+	}
+	return OrderClosed
+}
+
+// setIds fills in whichever of the order's ids are not yet known.
+func setIds(order *Order, orderId Id, clientOrderId string) {
+	if order.OrderId == "" {
+		order.OrderId = orderId
+	}
+	if order.ClientOrderId == "" {
+		order.ClientOrderId = clientOrderId
+	}
+}
+
+func updateOrderFromEvent(order *Order, ev OrderEvent) {
+	if ev.OrderId != "" {
+		order.OrderId = ev.OrderId
+	}
+	if ev.ClientOrderId != "" {
+		order.ClientOrderId = ev.ClientOrderId
+	}
+	if ev.Symbol != "" {
+		order.Symbol = ev.Symbol
+	}
+	if ev.Side != "" {
+		order.Side = ev.Side
+	}
+	if ev.OrderType != "" {
+		order.Type = ev.OrderType
+	}
+	if ev.Timestamp != 0 {
+		order.Timestamp = ev.Timestamp
+	}
+
+	order.IsLive = ev.IsLive
+	order.IsCancelled = ev.IsCancelled
+	order.IsHidden = ev.IsHidden
+	order.Price = ev.Price
+	order.ExecutedAmount = ev.ExecutedAmount
+	order.RemainingAmount = ev.RemainingAmount
+	order.OriginalAmount = ev.OriginalAmount
+	order.AvgExecutionPrice = ev.AvgExecutionPrice
+}
+
+func fillFromTrade(trade Trade) OrderFill {
+	liquidity := "Maker"
+	if trade.Aggressor {
+		liquidity = "Taker"
+	}
+
+	return OrderFill{
+		TradeId:     trade.TradeId,
+		Liquidity:   liquidity,
+		Price:       trade.Price,
+		Amount:      trade.Amount,
+		Fee:         trade.FeeAmount,
+		FeeCurrency: trade.FeeCurrency,
+	}
+}
+
+func hasFill(fills []OrderFill, tradeId Id) bool {
+	for _, fill := range fills {
+		if fill.TradeId == tradeId {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,175 @@
+package gemini
+
+import "testing"
+
+func TestOrderStateCanTransition(t *testing.T) {
+
+	all := []OrderState{
+		OrderPending, OrderAccepted, OrderBooked, OrderPartiallyFilled,
+		OrderFilled, OrderCancelled, OrderRejected, OrderClosed,
+	}
+
+	allowed := map[OrderState][]OrderState{
+		OrderPending:         {OrderAccepted, OrderBooked, OrderPartiallyFilled, OrderFilled, OrderCancelled, OrderRejected, OrderClosed},
+		OrderAccepted:        {OrderBooked, OrderPartiallyFilled, OrderFilled, OrderCancelled, OrderRejected, OrderClosed},
+		OrderBooked:          {OrderPartiallyFilled, OrderFilled, OrderCancelled, OrderClosed},
+		OrderPartiallyFilled: {OrderPartiallyFilled, OrderFilled, OrderCancelled, OrderClosed},
+		OrderFilled:          {OrderClosed},
+		OrderCancelled:       {OrderClosed},
+		OrderRejected:        {OrderClosed},
+		OrderClosed:          {},
+	}
+
+	for _, from := range all {
+		want := make(map[OrderState]bool)
+		for _, to := range allowed[from] {
+			want[to] = true
+		}
+		for _, to := range all {
+			if got := from.CanTransition(to); got != want[to] {
+				t.Errorf("%v -> %v: got %v, want %v", from, to, got, want[to])
+			}
+		}
+	}
+}
+
+func TestOrderManagerFillBeforeNewOrderResponse(t *testing.T) {
+
+	m := NewOrderManager(nil)
+
+	err := m.Apply(OrderEvent{
+		Type:            "fill",
+		OrderId:         "1",
+		ClientOrderId:   "c1",
+		IsLive:          false,
+		ExecutedAmount:  2,
+		RemainingAmount: 0,
+		OriginalAmount:  2,
+		Fill:            OrderFill{TradeId: "t1", Amount: 2, Price: 100},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the NewOrder response, taken before the fill, arrives afterwards
+	m.Track(Order{
+		OrderId:         "1",
+		ClientOrderId:   "c1",
+		IsLive:          true,
+		RemainingAmount: 2,
+		OriginalAmount:  2,
+	})
+
+	mo, ok := m.Get("c1")
+	if !ok {
+		t.Fatal("order not found")
+	}
+	if mo.State != OrderFilled {
+		t.Errorf("state: got %v, want %v", mo.State, OrderFilled)
+	}
+	if mo.ExecutedAmount != 2 || mo.RemainingAmount != 0 {
+		t.Errorf("amounts overwritten by stale snapshot: executed %v, remaining %v", mo.ExecutedAmount, mo.RemainingAmount)
+	}
+	if len(mo.Fills) != 1 {
+		t.Errorf("fills: got %d, want 1", len(mo.Fills))
+	}
+	if len(m.Open()) != 0 {
+		t.Errorf("filled order reported as open")
+	}
+}
+
+func TestOrderManagerNewOrderResponseBeforeAccepted(t *testing.T) {
+
+	m := NewOrderManager(nil)
+
+	m.Track(Order{
+		OrderId:         "1",
+		ClientOrderId:   "c1",
+		IsLive:          true,
+		RemainingAmount: 2,
+		OriginalAmount:  2,
+	})
+
+	for _, typ := range []string{"accepted", "initial", "booked"} {
+		err := m.Apply(OrderEvent{Type: typ, OrderId: "1", ClientOrderId: "c1", IsLive: true, RemainingAmount: 2})
+		if err != nil {
+			t.Errorf("%v event after booked snapshot: %v", typ, err)
+		}
+	}
+
+	mo, _ := m.Get("1")
+	if mo.State != OrderBooked {
+		t.Errorf("state: got %v, want %v", mo.State, OrderBooked)
+	}
+}
+
+func TestOrderManagerFillAfterFilledSnapshot(t *testing.T) {
+
+	m := NewOrderManager(nil)
+
+	m.Track(Order{OrderId: "1", ExecutedAmount: 2, OriginalAmount: 2})
+
+	err := m.Apply(OrderEvent{
+		Type:            "fill",
+		OrderId:         "1",
+		ExecutedAmount:  1,
+		RemainingAmount: 1,
+		Fill:            OrderFill{TradeId: "t1", Amount: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mo, _ := m.Get("1")
+	if mo.State != OrderFilled {
+		t.Errorf("state: got %v, want %v", mo.State, OrderFilled)
+	}
+	if mo.ExecutedAmount != 2 {
+		t.Errorf("executed amount: got %v, want 2", mo.ExecutedAmount)
+	}
+	if len(mo.Fills) != 1 {
+		t.Errorf("fills: got %d, want 1", len(mo.Fills))
+	}
+}
+
+func TestOrderManagerPartialFillSnapshots(t *testing.T) {
+
+	m := NewOrderManager(nil)
+
+	m.Track(Order{OrderId: "1", IsLive: true, ExecutedAmount: 1, RemainingAmount: 3})
+	m.Track(Order{OrderId: "1", IsLive: true, ExecutedAmount: 2, RemainingAmount: 2})
+	m.Track(Order{OrderId: "1", IsLive: true, ExecutedAmount: 1, RemainingAmount: 3})
+
+	mo, _ := m.Get("1")
+	if mo.State != OrderPartiallyFilled || mo.ExecutedAmount != 2 {
+		t.Errorf("got %v with %v executed, want %v with 2", mo.State, mo.ExecutedAmount, OrderPartiallyFilled)
+	}
+}
+
+func TestOrderManagerInvalidTransition(t *testing.T) {
+
+	m := NewOrderManager(nil)
+
+	m.Track(Order{OrderId: "1", IsLive: true, RemainingAmount: 2})
+
+	if err := m.Apply(OrderEvent{Type: "rejected", OrderId: "1"}); err == nil {
+		t.Error("expected an error rejecting a booked order")
+	}
+
+	mo, _ := m.Get("1")
+	if mo.State != OrderBooked {
+		t.Errorf("state: got %v, want %v", mo.State, OrderBooked)
+	}
+}
+
+func TestOrderManagerClientOrderIdLearned(t *testing.T) {
+
+	m := NewOrderManager(nil)
+
+	m.Apply(OrderEvent{Type: "fill", OrderId: "1", RemainingAmount: 0, Fill: OrderFill{TradeId: "t1"}})
+	m.Track(Order{OrderId: "1", ClientOrderId: "c1", IsLive: true, RemainingAmount: 1})
+
+	if _, ok := m.Get("c1"); !ok {
+		t.Error("client order id from stale snapshot not indexed")
+	}
+}
@@ -0,0 +1,299 @@
+package gemini
+
+import (
+	"math"
+	"strings"
+	"sync"
+)
+
+type CostMethod int
+
+// amounts smaller than this are floating point residue from matching lots,
+// such as 0.1 + 0.2 - 0.3, and are treated as zero
+const lotEpsilon = 1e-12
+
+const (
+	FIFO CostMethod = iota
+	LIFO
+	AverageCost
+)
+
+// quote currencies in the order they should be matched against the end of a
+// symbol, longest first so that "btcgusd" is not read as "btcg" / "usd"
+var quoteCurrencies = []string{"usdt", "gusd", "usdc", "dai", "usd", "eur", "gbp", "sgd", "aud", "cad", "hkd", "btc", "eth"}
+
+// SplitSymbol splits a symbol such as "btcusd" into its base and quote
+// currencies. The quote is empty if it is not a known currency.
+func SplitSymbol(symbol string) (base, quote string) {
+	symbol = strings.ToLower(symbol)
+	for _, q := range quoteCurrencies {
+		if strings.HasSuffix(symbol, q) && len(symbol) > len(q) {
+			return symbol[:len(symbol)-len(q)], q
+		}
+	}
+	return symbol, ""
+}
+
+type Lot struct {
+	Amount    float64 // negative for a short lot
+	Price     float64
+	Timestamp int64
+}
+
+// Holding is the position in the base currency of a symbol along with its
+// cost basis and realized P&L, both in the quote currency.
+type Holding struct {
+	Symbol   string
+	Amount   float64
+	Lots     []Lot
+	Realized float64
+}
+
+// AverageCost returns the average price of the open lots.
+func (h Holding) AverageCost() float64 {
+	var amount, cost float64
+	for _, lot := range h.Lots {
+		amount += lot.Amount
+		cost += lot.Amount * lot.Price
+	}
+	if amount == 0 {
+		return 0
+	}
+	return cost / amount
+}
+
+// Unrealized returns the P&L of the open lots if marked at the given price.
+func (h Holding) Unrealized(price float64) float64 {
+	var pnl float64
+	for _, lot := range h.Lots {
+		pnl += lot.Amount * (price - lot.Price)
+	}
+	return pnl
+}
+
+// Portfolio accounts for positions, cost basis, P&L and fees from trades and
+// fills. Trades are keyed by trade id, so the same trade seen through both
+// PastTrades and an order event fill is only counted once.
+type Portfolio struct {
+	method CostMethod
+
+	mu        sync.RWMutex
+	holdings  map[string]*Holding
+	positions map[string]float64
+	fees      map[string]float64
+	seen      map[Id]bool
+}
+
+func NewPortfolio(method CostMethod) *Portfolio {
+	return &Portfolio{
+		method:    method,
+		holdings:  make(map[string]*Holding),
+		positions: make(map[string]float64),
+		fees:      make(map[string]float64),
+		seen:      make(map[Id]bool),
+	}
+}
+
+// AddTrade records a trade returned from PastTrades for the given symbol.
+// Broken trades are ignored.
+func (p *Portfolio) AddTrade(symbol string, trade Trade) {
+	if trade.Broken {
+		return
+	}
+	p.add(symbol, trade.TradeId, trade.Type, trade.Amount, trade.Price, trade.Timestamp, trade.FeeCurrency, trade.FeeAmount)
+}
+
+// AddTrades records a list of trades returned from PastTrades.
+func (p *Portfolio) AddTrades(symbol string, trades []Trade) {
+	for _, trade := range trades {
+		p.AddTrade(symbol, trade)
+	}
+}
+
+// AddFill records a fill from an order event.
+func (p *Portfolio) AddFill(ev OrderEvent) {
+	p.add(ev.Symbol, ev.Fill.TradeId, ev.Side, ev.Fill.Amount, ev.Fill.Price, ev.Timestamp, ev.Fill.FeeCurrency, ev.Fill.Fee)
+}
+
+func (p *Portfolio) add(symbol string, tradeId Id, side string, amount, price float64, timestamp int64, feeCurrency string, fee float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if tradeId != "" {
+		if p.seen[tradeId] {
+			return
+		}
+		p.seen[tradeId] = true
+	}
+
+	symbol = strings.ToLower(symbol)
+	base, quote := SplitSymbol(symbol)
+
+	signed := signedAmount(strings.ToLower(side), amount)
+
+	p.positions[base] += signed
+	if quote != "" {
+		p.positions[quote] -= signed * price
+	}
+
+	if fee != 0 {
+		feeCurrency = strings.ToLower(feeCurrency)
+		p.fees[feeCurrency] += fee
+		p.positions[feeCurrency] -= fee
+	}
+
+	h, ok := p.holdings[symbol]
+	if !ok {
+		h = &Holding{Symbol: symbol}
+		p.holdings[symbol] = h
+	}
+
+	p.apply(h, Lot{Amount: signed, Price: price, Timestamp: timestamp})
+}
+
+// apply matches a new lot against the open lots of the opposite sign,
+// realizing P&L, and adds whatever remains as an open lot.
+func (p *Portfolio) apply(h *Holding, lot Lot) {
+
+	h.Amount += lot.Amount
+	if isDust(h.Amount) {
+		h.Amount = 0
+	}
+
+	for !isDust(lot.Amount) && len(h.Lots) > 0 {
+
+		idx := 0
+		if p.method == LIFO {
+			idx = len(h.Lots) - 1
+		}
+
+		open := &h.Lots[idx]
+		if (open.Amount > 0) == (lot.Amount > 0) {
+			break
+		}
+
+		matched := lot.Amount
+		if math.Abs(matched) > math.Abs(open.Amount) {
+			matched = -open.Amount
+		}
+
+		// closing a long realizes (sell - cost), closing a short (cost - buy)
+		h.Realized += -matched * (lot.Price - open.Price)
+
+		open.Amount += matched
+		lot.Amount -= matched
+
+		if isDust(open.Amount) {
+			h.Lots = append(h.Lots[:idx], h.Lots[idx+1:]...)
+		}
+	}
+
+	if isDust(lot.Amount) {
+		return
+	}
+
+	if p.method == AverageCost && len(h.Lots) > 0 {
+		open := &h.Lots[0]
+		total := open.Amount + lot.Amount
+		open.Price = (open.Amount*open.Price + lot.Amount*lot.Price) / total
+		open.Amount = total
+		open.Timestamp = lot.Timestamp
+		return
+	}
+
+	h.Lots = append(h.Lots, lot)
+}
+
+func isDust(amount float64) bool {
+	return math.Abs(amount) < lotEpsilon
+}
+
+// Position returns the net amount of a currency from all recorded trades,
+// less fees paid in that currency.
+func (p *Portfolio) Position(currency string) float64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.positions[strings.ToLower(currency)]
+}
+
+// Positions returns the net amount of every currency traded.
+func (p *Portfolio) Positions() map[string]float64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	positions := make(map[string]float64, len(p.positions))
+	for currency, amount := range p.positions {
+		positions[currency] = amount
+	}
+	return positions
+}
+
+// Holding returns the holding for a symbol.
+func (p *Portfolio) Holding(symbol string) Holding {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	h, ok := p.holdings[strings.ToLower(symbol)]
+	if !ok {
+		return Holding{Symbol: symbol}
+	}
+
+	holding := *h
+	holding.Lots = append([]Lot(nil), h.Lots...)
+	return holding
+}
+
+// Holdings returns the holdings for every symbol traded.
+func (p *Portfolio) Holdings() []Holding {
+	p.mu.RLock()
+	symbols := make([]string, 0, len(p.holdings))
+	for symbol := range p.holdings {
+		symbols = append(symbols, symbol)
+	}
+	p.mu.RUnlock()
+
+	holdings := make([]Holding, 0, len(symbols))
+	for _, symbol := range symbols {
+		holdings = append(holdings, p.Holding(symbol))
+	}
+	return holdings
+}
+
+// Fees returns the total fees paid, by fee currency.
+func (p *Portfolio) Fees() map[string]float64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	fees := make(map[string]float64, len(p.fees))
+	for currency, amount := range p.fees {
+		fees[currency] = amount
+	}
+	return fees
+}
+
+// Unrealized returns the unrealized P&L of every holding with open lots,
+// marked against the Ticker mid price of its symbol.
+func (p *Portfolio) Unrealized(api *Api) (map[string]float64, error) {
+
+	pnl := make(map[string]float64)
+
+	for _, h := range p.Holdings() {
+		if len(h.Lots) == 0 {
+			continue
+		}
+
+		ticker, err := api.Ticker(h.Symbol)
+		if err != nil {
+			return pnl, err
+		}
+
+		price := ticker.Last
+		if ticker.Bid > 0 && ticker.Ask > 0 {
+			price = (ticker.Bid + ticker.Ask) / 2
+		}
+
+		pnl[h.Symbol] = h.Unrealized(price)
+	}
+
+	return pnl, nil
+}
@@ -0,0 +1,156 @@
+package gemini
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func trade(id, side string, amount, price float64) Trade {
+	return Trade{TradeId: Id(id), Type: side, Amount: amount, Price: price}
+}
+
+func TestPortfolioRealized(t *testing.T) {
+
+	trades := []Trade{
+		trade("1", "Buy", 1, 100),
+		trade("2", "Buy", 1, 200),
+		trade("3", "Sell", 1, 300),
+	}
+
+	tests := []struct {
+		method   CostMethod
+		realized float64
+		cost     float64
+	}{
+		{FIFO, 200, 200},
+		{LIFO, 100, 100},
+		{AverageCost, 150, 150},
+	}
+
+	for _, tt := range tests {
+		p := NewPortfolio(tt.method)
+		p.AddTrades("btcusd", trades)
+
+		h := p.Holding("btcusd")
+		if !near(h.Realized, tt.realized) {
+			t.Errorf("method %v: realized %v, want %v", tt.method, h.Realized, tt.realized)
+		}
+		if !near(h.Amount, 1) {
+			t.Errorf("method %v: amount %v, want 1", tt.method, h.Amount)
+		}
+		if !near(h.AverageCost(), tt.cost) {
+			t.Errorf("method %v: average cost %v, want %v", tt.method, h.AverageCost(), tt.cost)
+		}
+	}
+}
+
+func TestPortfolioShort(t *testing.T) {
+
+	p := NewPortfolio(FIFO)
+	p.AddTrades("ethusd", []Trade{
+		trade("1", "Sell", 2, 50),
+		trade("2", "Buy", 1, 40),
+	})
+
+	h := p.Holding("ethusd")
+	if !near(h.Realized, 10) {
+		t.Errorf("realized %v, want 10", h.Realized)
+	}
+	if !near(h.Amount, -1) {
+		t.Errorf("amount %v, want -1", h.Amount)
+	}
+	if !near(h.Unrealized(30), 20) {
+		t.Errorf("unrealized %v, want 20", h.Unrealized(30))
+	}
+
+	// buying through the short closes it and opens a long
+	p.AddTrade("ethusd", trade("3", "Buy", 2, 60))
+
+	h = p.Holding("ethusd")
+	if !near(h.Realized, 0) {
+		t.Errorf("realized %v, want 0", h.Realized)
+	}
+	if len(h.Lots) != 1 || !near(h.Lots[0].Amount, 1) || !near(h.Lots[0].Price, 60) {
+		t.Errorf("lots %+v, want one long lot of 1 at 60", h.Lots)
+	}
+}
+
+func TestPortfolioFees(t *testing.T) {
+
+	p := NewPortfolio(FIFO)
+
+	buy := trade("1", "Buy", 1, 100)
+	buy.FeeCurrency = "USD"
+	buy.FeeAmount = 0.35
+	p.AddTrade("btcusd", buy)
+
+	if fee := p.Fees()["usd"]; !near(fee, 0.35) {
+		t.Errorf("fees %v, want 0.35", fee)
+	}
+	if pos := p.Position("usd"); !near(pos, -100.35) {
+		t.Errorf("usd position %v, want -100.35", pos)
+	}
+	if pos := p.Position("btc"); !near(pos, 1) {
+		t.Errorf("btc position %v, want 1", pos)
+	}
+}
+
+func TestPortfolioDuplicateTrades(t *testing.T) {
+
+	p := NewPortfolio(FIFO)
+	p.AddTrade("btcusd", trade("1", "Buy", 1, 100))
+	p.AddTrade("btcusd", trade("1", "Buy", 1, 100))
+
+	// the same trade seen as an order event fill
+	p.AddFill(OrderEvent{
+		Symbol: "btcusd",
+		Side:   "buy",
+		Fill:   OrderFill{TradeId: "1", Amount: 1, Price: 100},
+	})
+
+	if h := p.Holding("btcusd"); !near(h.Amount, 1) {
+		t.Errorf("amount %v, want 1", h.Amount)
+	}
+
+	p.AddTrade("btcusd", Trade{TradeId: "2", Type: "Buy", Amount: 1, Price: 100, Broken: true})
+	if h := p.Holding("btcusd"); !near(h.Amount, 1) {
+		t.Errorf("broken trade counted: amount %v, want 1", h.Amount)
+	}
+}
+
+func TestPortfolioDust(t *testing.T) {
+
+	for _, method := range []CostMethod{FIFO, LIFO, AverageCost} {
+		p := NewPortfolio(method)
+		p.AddTrades("btcusd", []Trade{
+			trade("1", "Buy", 0.1, 100),
+			trade("2", "Buy", 0.2, 100),
+			trade("3", "Sell", 0.3, 100),
+		})
+
+		h := p.Holding("btcusd")
+		if h.Amount != 0 || len(h.Lots) != 0 {
+			t.Errorf("method %v: amount %v with lots %+v, want a flat holding", method, h.Amount, h.Lots)
+		}
+	}
+}
+
+func TestSplitSymbol(t *testing.T) {
+
+	tests := []struct{ symbol, base, quote string }{
+		{"btcusd", "btc", "usd"},
+		{"BTCGUSD", "btc", "gusd"},
+		{"ethbtc", "eth", "btc"},
+		{"xyz", "xyz", ""},
+	}
+
+	for _, tt := range tests {
+		if base, quote := SplitSymbol(tt.symbol); base != tt.base || quote != tt.quote {
+			t.Errorf("%v: got %v/%v, want %v/%v", tt.symbol, base, quote, tt.base, tt.quote)
+		}
+	}
+}
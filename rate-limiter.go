@@ -0,0 +1,72 @@
+package gemini
+
+import (
+	"sync"
+	"time"
+)
+
+// Gemini allows up to 600 private requests per minute per account, and
+// recommends no more than 5 per second.
+const (
+	PRIVATE_REQUESTS_PER_SECOND = 5
+	PRIVATE_REQUESTS_BURST      = 10
+)
+
+// RateLimiter is a token bucket limiting the rate of requests. A single
+// RateLimiter may be shared by several Apis that count against the same
+// budget, such as multiple keys for one account.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a RateLimiter allowing perSecond requests on
+// average, with bursts of up to burst requests.
+func NewRateLimiter(perSecond float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:   perSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a request may be made and returns how long it waited.
+func (l *RateLimiter) Wait() time.Duration {
+
+	l.mu.Lock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	// take the token now, going into debt if need be, so that waiters are
+	// served in order
+	l.tokens--
+
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+
+	l.mu.Unlock()
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+
+	return wait
+}
+
+// SetRateLimiter sets the RateLimiter that every private request made
+// through the Api waits on. Public requests, which Gemini limits separately,
+// do not wait. Passing nil removes the limit.
+func (api *Api) SetRateLimiter(limiter *RateLimiter) {
+	api.limiter = limiter
+}
@@ -0,0 +1,37 @@
+package gemini
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRateLimiterSkipsPublicRequests(t *testing.T) {
+
+	api := fakeGemini(t, map[string]fakeHandler{
+		SYMBOLS_URI: func(map[string]interface{}) (int, interface{}) {
+			return http.StatusOK, []string{"btcusd"}
+		},
+		HEARTBEAT_URI: func(map[string]interface{}) (int, interface{}) {
+			return http.StatusOK, map[string]string{"result": "ok"}
+		},
+	})
+
+	limiter := NewRateLimiter(0.001, 1)
+	api.SetRateLimiter(limiter)
+
+	for i := 0; i < 3; i++ {
+		if _, err := api.Symbols(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if limiter.tokens != 1 {
+		t.Errorf("public requests took %v tokens", 1-limiter.tokens)
+	}
+
+	if _, err := api.Heartbeat(); err != nil {
+		t.Fatal(err)
+	}
+	if limiter.tokens >= 1 {
+		t.Error("private request took no token")
+	}
+}
@@ -0,0 +1,342 @@
+package gemini
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RiskLimits configures the pre-trade checks for a symbol. A zero value for
+// any limit disables that check.
+type RiskLimits struct {
+	MaxOrderAmount     float64 // fat-finger limit on the amount of a single order
+	MaxOrderNotional   float64 // limit on amount * price of a single order
+	MaxPosition        float64 // limit on the absolute position if the order and all open orders on its side fill
+	PriceBand          float64 // max deviation from the ticker mid, as a fraction (0.05 = 5%)
+	MaxOpenOrders      int
+	MaxOrdersPerSecond int
+}
+
+type RiskRejectError struct {
+	Symbol string
+	Check  string
+	Reason string
+}
+
+func (e *RiskRejectError) Error() string {
+	return fmt.Sprintf("[%v] %v: %v", e.Check, e.Symbol, e.Reason)
+}
+
+// RiskGuard validates orders before they are sent with NewOrder. Limits are
+// configured per symbol, fall back to a default, and may be changed at any
+// time. Positions are not fetched from Gemini; they are maintained with
+// SetPosition and AddFill.
+//
+// The max position check assumes the worst: that every open order on the
+// same side as the new one fills. Open orders are taken from Orders if it is
+// set, and otherwise from ActiveOrders along with any order placed through
+// the guard since. While an order placed through the guard is in flight, its
+// amount and an open order slot are reserved, so that concurrent calls to
+// NewOrder cannot together exceed MaxPosition or MaxOpenOrders. Positions are
+// not changed by orders placed through the guard, so fills, including any in
+// the NewOrder response, must be reported with AddFill, typically from the
+// order events stream.
+type RiskGuard struct {
+	api *Api
+
+	// Orders, if set, is used to find open orders and records every order
+	// placed through the guard. Otherwise ActiveOrders is called.
+	Orders *OrderManager
+
+	mu        sync.Mutex
+	defaults  RiskLimits
+	limits    map[string]RiskLimits
+	positions map[string]float64
+	sent      []time.Time
+
+	// amounts and open order slots reserved by orders in flight
+	pending       map[string]exposure
+	pendingOrders int
+
+	// orders placed through the guard that a snapshot from ActiveOrders
+	// may not include yet
+	placed map[Id]placedOrder
+}
+
+// exposure is the amount of a symbol that open orders could buy and sell.
+type exposure struct {
+	buys  float64
+	sells float64
+}
+
+func (e *exposure) add(side string, amount float64) {
+	if side == "sell" {
+		e.sells += amount
+	} else {
+		e.buys += amount
+	}
+}
+
+type placedOrder struct {
+	Order
+	at time.Time
+}
+
+func NewRiskGuard(api *Api, defaults RiskLimits) *RiskGuard {
+	return &RiskGuard{
+		api:       api,
+		defaults:  defaults,
+		limits:    make(map[string]RiskLimits),
+		positions: make(map[string]float64),
+		pending:   make(map[string]exposure),
+		placed:    make(map[Id]placedOrder),
+	}
+}
+
+// SetDefaultLimits replaces the limits used for symbols without their own.
+func (g *RiskGuard) SetDefaultLimits(limits RiskLimits) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.defaults = limits
+}
+
+// SetLimits replaces the limits for a symbol.
+func (g *RiskGuard) SetLimits(symbol string, limits RiskLimits) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.limits[symbol] = limits
+}
+
+// Limits returns the limits in effect for a symbol.
+func (g *RiskGuard) Limits(symbol string) RiskLimits {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.limitsFor(symbol)
+}
+
+// SetPosition sets the current position in the base currency of a symbol.
+// Short positions are negative.
+func (g *RiskGuard) SetPosition(symbol string, amount float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.positions[symbol] = amount
+}
+
+// AddFill adjusts the position for a symbol by a filled amount.
+func (g *RiskGuard) AddFill(symbol, side string, amount float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.positions[symbol] += signedAmount(side, amount)
+}
+
+// Position returns the current position for a symbol.
+func (g *RiskGuard) Position(symbol string) float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.positions[symbol]
+}
+
+// NewOrder runs the pre-trade checks and, if they pass, places the order.
+// A failed check returns a *RiskRejectError and the order is not sent.
+func (g *RiskGuard) NewOrder(symbol, clientOrderId string, amount, price float64, side string, options []string) (Order, error) {
+
+	release, err := g.check(symbol, amount, price, side, true)
+	if err != nil {
+		return Order{}, err
+	}
+
+	var order Order
+	if g.Orders != nil {
+		order, err = g.Orders.NewOrder(symbol, clientOrderId, amount, price, side, options)
+	} else {
+		order, err = g.api.NewOrder(symbol, clientOrderId, amount, price, side, options)
+	}
+
+	release(order, err)
+
+	return order, err
+}
+
+// Check validates an order against the limits for its symbol without placing
+// it. Checks that need no round trip are run first. A passing check counts
+// towards the orders per second limit.
+func (g *RiskGuard) Check(symbol string, amount, price float64, side string) error {
+	_, err := g.check(symbol, amount, price, side, false)
+	return err
+}
+
+// check runs the checks for an order. The position, open order and order
+// rate checks run together under the lock, counting orders in flight, and if
+// reserve is set the order is added to those in flight until release is
+// called with the result of placing it.
+func (g *RiskGuard) check(symbol string, amount, price float64, side string, reserve bool) (release func(Order, error), err error) {
+
+	g.mu.Lock()
+	limits := g.limitsFor(symbol)
+	g.mu.Unlock()
+
+	reject := func(check, format string, args ...interface{}) error {
+		return &RiskRejectError{
+			Symbol: symbol,
+			Check:  check,
+			Reason: fmt.Sprintf(format, args...),
+		}
+	}
+
+	if limits.MaxOrderAmount > 0 && amount > limits.MaxOrderAmount {
+		return nil, reject("max_order_amount", "amount %v exceeds %v", amount, limits.MaxOrderAmount)
+	}
+
+	if notional := amount * price; limits.MaxOrderNotional > 0 && notional > limits.MaxOrderNotional {
+		return nil, reject("max_order_notional", "notional %v exceeds %v", notional, limits.MaxOrderNotional)
+	}
+
+	var active []Order
+	var fetched time.Time
+	if (limits.MaxOpenOrders > 0 || limits.MaxPosition > 0) && g.Orders == nil {
+		fetched = time.Now()
+		if active, err = g.api.ActiveOrders(); err != nil {
+			return nil, err
+		}
+	}
+
+	if limits.PriceBand > 0 {
+		ticker, err := g.api.Ticker(symbol)
+		if err != nil {
+			return nil, err
+		}
+		mid := (ticker.Bid + ticker.Ask) / 2
+		if mid > 0 && math.Abs(price-mid)/mid > limits.PriceBand {
+			return nil, reject("price_band", "price %v is outside %v of mid %v", price, limits.PriceBand, mid)
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	open, resting := g.openOrders(symbol, active, fetched)
+	pending := g.pending[symbol]
+
+	if limits.MaxPosition > 0 {
+		projected := g.positions[symbol] + resting.buys + pending.buys + amount
+		if side == "sell" {
+			projected = g.positions[symbol] - resting.sells - pending.sells - amount
+		}
+		if math.Abs(projected) > limits.MaxPosition {
+			return nil, reject("max_position", "position %v would exceed %v", projected, limits.MaxPosition)
+		}
+	}
+
+	if open += g.pendingOrders; limits.MaxOpenOrders > 0 && open >= limits.MaxOpenOrders {
+		return nil, reject("max_open_orders", "%v orders already open", open)
+	}
+
+	if limits.MaxOrdersPerSecond > 0 && !g.allow(limits.MaxOrdersPerSecond) {
+		return nil, reject("max_orders_per_second", "more than %v orders in the last second", limits.MaxOrdersPerSecond)
+	}
+
+	if !reserve {
+		return func(Order, error) {}, nil
+	}
+
+	pending.add(side, amount)
+	g.pending[symbol] = pending
+	g.pendingOrders++
+
+	return func(order Order, err error) {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+
+		pending := g.pending[symbol]
+		pending.add(side, -amount)
+		g.pending[symbol] = pending
+		g.pendingOrders--
+
+		if err == nil && g.Orders == nil && order.IsLive {
+			g.placed[order.OrderId] = placedOrder{Order: order, at: time.Now()}
+		}
+	}, nil
+}
+
+// limitsFor returns the limits for a symbol. The caller must hold the lock.
+func (g *RiskGuard) limitsFor(symbol string) RiskLimits {
+	if limits, ok := g.limits[symbol]; ok {
+		return limits
+	}
+	return g.defaults
+}
+
+// openOrders returns the number of open orders and the amount the open
+// orders for the symbol could still buy and sell. Without an OrderManager
+// they are taken from the ActiveOrders snapshot requested at fetched, along
+// with orders placed through the guard since then that it may not include.
+// The caller must hold the lock.
+func (g *RiskGuard) openOrders(symbol string, active []Order, fetched time.Time) (int, exposure) {
+
+	var count int
+	var resting exposure
+
+	add := func(order Order) {
+		count++
+		if strings.EqualFold(order.Symbol, symbol) {
+			resting.add(order.Side, order.RemainingAmount)
+		}
+	}
+
+	if g.Orders != nil {
+		for _, mo := range g.Orders.Open() {
+			add(mo.Order)
+		}
+		return count, resting
+	}
+
+	live := make(map[Id]bool, len(active))
+	for _, order := range active {
+		live[order.OrderId] = true
+		add(order)
+	}
+
+	for id, placed := range g.placed {
+		if placed.at.Before(fetched) {
+			// placed before the snapshot, which is the better record
+			delete(g.placed, id)
+			continue
+		}
+		if !live[id] {
+			add(placed.Order)
+		}
+	}
+
+	return count, resting
+}
+
+// allow records an order against a one second sliding window and reports
+// whether it is within the limit. The caller must hold the lock.
+func (g *RiskGuard) allow(limit int) bool {
+	now := time.Now()
+	cutoff := now.Add(-time.Second)
+
+	sent := g.sent[:0]
+	for _, t := range g.sent {
+		if t.After(cutoff) {
+			sent = append(sent, t)
+		}
+	}
+	g.sent = sent
+
+	if len(g.sent) >= limit {
+		return false
+	}
+
+	g.sent = append(g.sent, now)
+	return true
+}
+
+func signedAmount(side string, amount float64) float64 {
+	if side == "sell" {
+		return -amount
+	}
+	return amount
+}
@@ -0,0 +1,128 @@
+package gemini
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// fakeBook is an exchange on which every order rests on the book.
+type fakeBook struct {
+	orders []map[string]interface{}
+}
+
+func (b *fakeBook) handlers() map[string]fakeHandler {
+	return map[string]fakeHandler{
+		NEW_ORDER_URI: func(params map[string]interface{}) (int, interface{}) {
+			order := map[string]interface{}{
+				"order_id":         strconv.Itoa(len(b.orders) + 1),
+				"symbol":           params["symbol"],
+				"side":             params["side"],
+				"price":            params["price"],
+				"original_amount":  params["amount"],
+				"remaining_amount": params["amount"],
+				"executed_amount":  "0",
+				"is_live":          true,
+			}
+			b.orders = append(b.orders, order)
+			return http.StatusOK, order
+		},
+		ACTIVE_ORDERS_URI: func(map[string]interface{}) (int, interface{}) {
+			return http.StatusOK, b.orders
+		},
+	}
+}
+
+func isRiskReject(err error, check string) bool {
+	var reject *RiskRejectError
+	return errors.As(err, &reject) && reject.Check == check
+}
+
+func TestRiskGuardMaxPositionCountsRestingOrders(t *testing.T) {
+
+	for _, managed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("managed=%v", managed), func(t *testing.T) {
+
+			book := &fakeBook{}
+			api := fakeGemini(t, book.handlers())
+
+			g := NewRiskGuard(api, RiskLimits{MaxPosition: 10})
+			if managed {
+				g.Orders = NewOrderManager(api)
+			}
+
+			if _, err := g.NewOrder("btcusd", "", 10, 100, "buy", nil); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := g.NewOrder("btcusd", "", 10, 100, "buy", nil)
+			if !isRiskReject(err, "max_position") {
+				t.Errorf("second resting buy: got %v, want a max_position reject", err)
+			}
+
+			// resting buys do not limit how much may be sold
+			if _, err := g.NewOrder("btcusd", "", 10, 110, "sell", nil); err != nil {
+				t.Errorf("sell against resting buy: %v", err)
+			}
+
+			// the resting orders of other symbols are not counted
+			if _, err := g.NewOrder("ethusd", "", 10, 100, "buy", nil); err != nil {
+				t.Errorf("buy of another symbol: %v", err)
+			}
+
+			if len(book.orders) != 3 {
+				t.Errorf("%d orders sent, want 3", len(book.orders))
+			}
+		})
+	}
+}
+
+func TestRiskGuardMaxPositionCountsFills(t *testing.T) {
+
+	book := &fakeBook{}
+	g := NewRiskGuard(fakeGemini(t, book.handlers()), RiskLimits{MaxPosition: 10})
+
+	g.AddFill("btcusd", "buy", 6)
+
+	if err := g.Check("btcusd", 5, 100, "buy"); !isRiskReject(err, "max_position") {
+		t.Errorf("got %v, want a max_position reject", err)
+	}
+	if err := g.Check("btcusd", 16, 100, "sell"); err != nil {
+		t.Errorf("sell to a short of 10: %v", err)
+	}
+}
+
+func TestRiskGuardMaxOpenOrders(t *testing.T) {
+
+	book := &fakeBook{}
+	g := NewRiskGuard(fakeGemini(t, book.handlers()), RiskLimits{MaxOpenOrders: 2})
+
+	for i := 0; i < 2; i++ {
+		if _, err := g.NewOrder("btcusd", "", 1, 100, "buy", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := g.NewOrder("btcusd", "", 1, 100, "buy", nil); !isRiskReject(err, "max_open_orders") {
+		t.Errorf("got %v, want a max_open_orders reject", err)
+	}
+}
+
+func TestRiskGuardOrderLimits(t *testing.T) {
+
+	g := NewRiskGuard(nil, RiskLimits{MaxOrderAmount: 5, MaxOrderNotional: 1000})
+
+	if err := g.Check("btcusd", 6, 1, "buy"); !isRiskReject(err, "max_order_amount") {
+		t.Errorf("got %v, want a max_order_amount reject", err)
+	}
+	if err := g.Check("btcusd", 5, 201, "buy"); !isRiskReject(err, "max_order_notional") {
+		t.Errorf("got %v, want a max_order_notional reject", err)
+	}
+
+	g.SetLimits("btcusd", RiskLimits{})
+	if err := g.Check("btcusd", 6, 1000, "buy"); err != nil {
+		t.Errorf("symbol limits not applied: %v", err)
+	}
+}
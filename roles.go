@@ -0,0 +1,115 @@
+package gemini
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Role string
+
+const (
+	RoleTrader      Role = "Trader"
+	RoleFundManager Role = "FundManager"
+	RoleAuditor     Role = "Auditor"
+)
+
+// Roles are the roles assigned to an api key, as returned by /v1/roles. The
+// endpoint does not report whether the key has "require heartbeat" enabled;
+// keys that do should be kept alive with a SessionKeeper.
+type Roles struct {
+	IsTrader       bool   `json:"isTrader"`
+	IsFundManager  bool   `json:"isFundManager"`
+	IsAuditor      bool   `json:"isAuditor"`
+	CounterpartyId string `json:"counterparty_id"`
+}
+
+// List returns the roles assigned to the key.
+func (r Roles) List() []Role {
+	var roles []Role
+	if r.IsTrader {
+		roles = append(roles, RoleTrader)
+	}
+	if r.IsFundManager {
+		roles = append(roles, RoleFundManager)
+	}
+	if r.IsAuditor {
+		roles = append(roles, RoleAuditor)
+	}
+	return roles
+}
+
+// Has reports whether the key has the given role.
+func (r Roles) Has(role Role) bool {
+	for _, assigned := range r.List() {
+		if assigned == role {
+			return true
+		}
+	}
+	return false
+}
+
+type RoleError struct {
+	Missing    []Role
+	Unexpected []Role
+}
+
+func (e *RoleError) Error() string {
+	var problems []string
+	if len(e.Missing) > 0 {
+		problems = append(problems, fmt.Sprintf("missing roles %v", e.Missing))
+	}
+	if len(e.Unexpected) > 0 {
+		problems = append(problems, fmt.Sprintf("unexpected roles %v", e.Unexpected))
+	}
+	return fmt.Sprintf("[InvalidRoles] api key has %v", strings.Join(problems, " and "))
+}
+
+// VerifyRoles checks that the key has exactly the given roles, returning a
+// *RoleError that lists any that are missing or unexpected.
+func (api *Api) VerifyRoles(required ...Role) (Roles, error) {
+
+	roles, err := api.Roles()
+	if err != nil {
+		return roles, err
+	}
+
+	var roleErr RoleError
+
+	for _, role := range required {
+		if !roles.Has(role) {
+			roleErr.Missing = append(roleErr.Missing, role)
+		}
+	}
+
+	for _, role := range roles.List() {
+		var wanted bool
+		for _, r := range required {
+			wanted = wanted || r == role
+		}
+		if !wanted {
+			roleErr.Unexpected = append(roleErr.Unexpected, role)
+		}
+	}
+
+	if len(roleErr.Missing) > 0 || len(roleErr.Unexpected) > 0 {
+		return roles, &roleErr
+	}
+
+	return roles, nil
+}
+
+// NewVerified returns a new Api like New, but first checks that the key has
+// exactly the given roles so that missing permissions are found at startup
+// rather than on the first request that needs them. It cannot check whether
+// the key requires a heartbeat; if it does, start a SessionKeeper before
+// placing orders.
+func NewVerified(live bool, key, secret string, roles ...Role) (*Api, error) {
+
+	api := New(live, key, secret)
+
+	if _, err := api.VerifyRoles(roles...); err != nil {
+		return nil, err
+	}
+
+	return api, nil
+}
@@ -2,7 +2,9 @@ package gemini
 
 import (
 	"encoding/json"
+	"fmt"
 	"strconv"
+	"strings"
 )
 
 // Past Trades
@@ -12,7 +14,6 @@ func (api *Api) PastTrades(symbol string, limitTrades int, timestamp int64) ([]T
 
 	params := map[string]interface{}{
 		"request":      PAST_TRADES_URI,
-		"nonce":        Nonce(),
 		"symbol":       symbol,
 		"limit_trades": limitTrades,
 		"timestamp":    timestamp,
@@ -36,7 +37,6 @@ func (api *Api) TradeVolume() ([][]TradeVolume, error) {
 	url := api.url + TRADE_VOLUME_URI
 	params := map[string]interface{}{
 		"request": TRADE_VOLUME_URI,
-		"nonce":   Nonce(),
 	}
 
 	var volumes [][]TradeVolume
@@ -51,13 +51,58 @@ func (api *Api) TradeVolume() ([][]TradeVolume, error) {
 	return volumes, nil
 }
 
+// Notional Volume
+func (api *Api) NotionalVolume() (NotionalVolume, error) {
+
+	url := api.url + NOTIONAL_VOLUME_URI
+	params := map[string]interface{}{
+		"request": NOTIONAL_VOLUME_URI,
+	}
+
+	var volume NotionalVolume
+
+	body, err := api.request("POST", url, params)
+	if err != nil {
+		return volume, err
+	}
+
+	json.Unmarshal(body, &volume)
+
+	return volume, nil
+}
+
 // Active Orders
 func (api *Api) ActiveOrders() ([]Order, error) {
 
 	url := api.url + ACTIVE_ORDERS_URI
 	params := map[string]interface{}{
 		"request": ACTIVE_ORDERS_URI,
-		"nonce":   Nonce(),
+	}
+
+	var orders []Order
+
+	body, err := api.request("POST", url, params)
+	if err != nil {
+		return nil, err
+	}
+
+	json.Unmarshal(body, &orders)
+
+	return orders, nil
+}
+
+// Order History
+func (api *Api) OrderHistory(symbol string, since int64, limit int) ([]Order, error) {
+
+	url := api.url + ORDER_HISTORY_URI
+	params := map[string]interface{}{
+		"request":      ORDER_HISTORY_URI,
+		"timestamp":    since,
+		"limit_orders": limit,
+	}
+
+	if symbol != "" {
+		params["symbol"] = symbol
 	}
 
 	var orders []Order
@@ -74,12 +119,27 @@ func (api *Api) ActiveOrders() ([]Order, error) {
 
 // Order Status
 func (api *Api) OrderStatus(orderId string) (Order, error) {
+	return api.OrderStatusBy(OrderStatusRequest{OrderId: orderId})
+}
+
+// Order Status by order id or client order id, optionally with trades.
+// Exactly one of the ids must be set.
+func (api *Api) OrderStatusBy(req OrderStatusRequest) (Order, error) {
+
+	if (req.OrderId == "") == (req.ClientOrderId == "") {
+		return Order{}, fmt.Errorf("order status needs exactly one of order id and client order id")
+	}
 
 	url := api.url + ORDER_STATUS_URI
 	params := map[string]interface{}{
-		"request":  ORDER_STATUS_URI,
-		"nonce":    Nonce(),
-		"order_id": orderId,
+		"request":        ORDER_STATUS_URI,
+		"include_trades": req.IncludeTrades,
+	}
+
+	if req.OrderId != "" {
+		params["order_id"] = req.OrderId
+	} else {
+		params["client_order_id"] = req.ClientOrderId
 	}
 
 	var order Order
@@ -89,6 +149,22 @@ func (api *Api) OrderStatus(orderId string) (Order, error) {
 		return order, err
 	}
 
+	// a lookup by client order id returns a list of orders, of which the
+	// most recent is used
+	if len(body) > 0 && body[0] == '[' {
+		var orders []Order
+		json.Unmarshal(body, &orders)
+		if len(orders) == 0 {
+			return order, fmt.Errorf("no order with client order id %v", req.ClientOrderId)
+		}
+		for _, o := range orders {
+			if o.Timestamp >= order.Timestamp {
+				order = o
+			}
+		}
+		return order, nil
+	}
+
 	json.Unmarshal(body, &order)
 
 	return order, nil
@@ -97,10 +173,13 @@ func (api *Api) OrderStatus(orderId string) (Order, error) {
 // New Order
 func (api *Api) NewOrder(symbol, clientOrderId string, amount, price float64, side string, options []string) (Order, error) {
 
+	if err := halt.check(); err != nil {
+		return Order{}, err
+	}
+
 	url := api.url + NEW_ORDER_URI
 	params := map[string]interface{}{
 		"request":         NEW_ORDER_URI,
-		"nonce":           Nonce(),
 		"client_order_id": clientOrderId,
 		"symbol":          symbol,
 		"amount":          strconv.FormatFloat(amount, 'f', -1, 64),
@@ -131,7 +210,6 @@ func (api *Api) CancelOrder(orderId string) (Order, error) {
 	url := api.url + CANCEL_ORDER_URI
 	params := map[string]interface{}{
 		"request":  CANCEL_ORDER_URI,
-		"nonce":    Nonce(),
 		"order_id": orderId,
 	}
 
@@ -153,7 +231,6 @@ func (api *Api) CancelAll() (CancelResult, error) {
 	url := api.url + CANCEL_ALL_URI
 	params := map[string]interface{}{
 		"request": CANCEL_ALL_URI,
-		"nonce":   Nonce(),
 	}
 
 	var res CancelResult
@@ -174,7 +251,6 @@ func (api *Api) CancelSession() (GenericResponse, error) {
 	url := api.url + CANCEL_SESSION_URI
 	params := map[string]interface{}{
 		"request": CANCEL_SESSION_URI,
-		"nonce":   Nonce(),
 	}
 
 	var res GenericResponse
@@ -195,7 +271,6 @@ func (api *Api) Heartbeat() (GenericResponse, error) {
 	url := api.url + HEARTBEAT_URI
 	params := map[string]interface{}{
 		"request": HEARTBEAT_URI,
-		"nonce":   Nonce(),
 	}
 
 	var res GenericResponse
@@ -216,7 +291,6 @@ func (api *Api) Balances() ([]FundBalance, error) {
 	url := api.url + BALANCES_URI
 	params := map[string]interface{}{
 		"request": BALANCES_URI,
-		"nonce":   Nonce(),
 	}
 
 	var balances []FundBalance
@@ -231,14 +305,81 @@ func (api *Api) Balances() ([]FundBalance, error) {
 	return balances, nil
 }
 
-// New Deposit Address
+// Notional Balances
+func (api *Api) NotionalBalances(currency string) ([]NotionalBalance, error) {
+
+	path := NOTIONAL_BALANCES_URI + currency
+	url := api.url + path
+	params := map[string]interface{}{
+		"request": path,
+	}
+
+	var balances []NotionalBalance
+
+	body, err := api.request("POST", url, params)
+	if err != nil {
+		return balances, err
+	}
+
+	json.Unmarshal(body, &balances)
+
+	return balances, nil
+}
+
+// Total Notional Balance returns the value of the whole account in the given
+// currency
+func (api *Api) TotalNotionalBalance(currency string) (float64, error) {
+
+	balances, err := api.NotionalBalances(currency)
+	if err != nil {
+		return 0, err
+	}
+
+	var total float64
+	for _, balance := range balances {
+		total += balance.AmountNotional
+	}
+
+	return total, nil
+}
+
+// Staking Balances
+func (api *Api) StakingBalances() ([]ProviderBalance, error) {
+	return api.providerBalances(STAKING_BALANCES_URI)
+}
+
+// Earn Balances
+func (api *Api) EarnBalances() ([]ProviderBalance, error) {
+	return api.providerBalances(EARN_BALANCES_URI)
+}
+
+func (api *Api) providerBalances(path string) ([]ProviderBalance, error) {
+
+	url := api.url + path
+	params := map[string]interface{}{
+		"request": path,
+	}
+
+	var balances []ProviderBalance
+
+	body, err := api.request("POST", url, params)
+	if err != nil {
+		return balances, err
+	}
+
+	json.Unmarshal(body, &balances)
+
+	return balances, nil
+}
+
+// New Deposit Address. Gemini takes the network, such as bitcoin or
+// ethereum, in place of the currency
 func (api *Api) NewDepositAddress(currency, label string) (DepositAddress, error) {
 
 	path := NEW_DEPOSIT_ADDRESS_URI + currency + "/newAddress"
 	url := api.url + path
 	params := map[string]interface{}{
 		"request": path,
-		"nonce":   Nonce(),
 		"label":   label,
 	}
 
@@ -254,19 +395,285 @@ func (api *Api) NewDepositAddress(currency, label string) (DepositAddress, error
 	return res, nil
 }
 
+// Deposit Addresses
+func (api *Api) DepositAddresses(network string) ([]DepositAddress, error) {
+
+	path := DEPOSIT_ADDRESSES_URI + network
+	url := api.url + path
+	params := map[string]interface{}{
+		"request": path,
+	}
+
+	var addresses []DepositAddress
+
+	body, err := api.request("POST", url, params)
+	if err != nil {
+		return nil, err
+	}
+
+	json.Unmarshal(body, &addresses)
+
+	return addresses, nil
+}
+
+// Deposit Address reuses the most recent address on the network with the
+// given label, creating a new one on the same network only if none exists
+func (api *Api) DepositAddress(network, label string) (DepositAddress, error) {
+
+	addresses, err := api.DepositAddresses(network)
+	if err != nil {
+		return DepositAddress{}, err
+	}
+
+	var found *DepositAddress
+	for i, address := range addresses {
+		if address.Label == label && (found == nil || address.Timestamp > found.Timestamp) {
+			found = &addresses[i]
+		}
+	}
+
+	if found != nil {
+		return *found, nil
+	}
+
+	return api.NewDepositAddress(network, label)
+}
+
+// Approved Addresses
+func (api *Api) ApprovedAddresses(network string) ([]ApprovedAddress, error) {
+
+	path := APPROVED_ADDRESSES_URI + network
+	url := api.url + path
+	params := map[string]interface{}{
+		"request": path,
+	}
+
+	var res struct {
+		ApprovedAddresses []ApprovedAddress `json:"approvedAddresses"`
+	}
+
+	body, err := api.request("POST", url, params)
+	if err != nil {
+		return nil, err
+	}
+
+	json.Unmarshal(body, &res)
+
+	return res.ApprovedAddresses, nil
+}
+
 // Withdraw Crypto Funds
 func (api *Api) WithdrawFunds(currency, address string, amount float64) (WithdrawFundsResult, error) {
 
-	path := WITHDRAW_FUNDS_URI + currency
+	return api.withdraw(WithdrawRequest{
+		Currency: currency,
+		Address:  address,
+		Amount:   amount,
+	})
+}
+
+// Withdraw estimates the fee for a withdrawal, unless the request carries
+// one, and then sends it, returning the fee estimate in the result
+func (api *Api) Withdraw(req WithdrawRequest) (WithdrawFundsResult, error) {
+
+	var fee WithdrawalFeeEstimate
+	if req.Fee != nil {
+		fee = *req.Fee
+	} else {
+		var err error
+		if fee, err = api.WithdrawalFeeEstimate(req); err != nil {
+			return WithdrawFundsResult{}, err
+		}
+	}
+
+	res, err := api.withdraw(req)
+	if err != nil {
+		return res, err
+	}
+
+	res.Fee = fee
+
+	return res, nil
+}
+
+func (api *Api) withdraw(req WithdrawRequest) (WithdrawFundsResult, error) {
+
+	path := WITHDRAW_FUNDS_URI + req.Currency
+	url := api.url + path
+	params := withdrawParams(path, req)
+
+	var res WithdrawFundsResult
+
+	body, err := api.request("POST", url, params)
+	if err != nil {
+		return res, err
+	}
+
+	json.Unmarshal(body, &res)
+
+	return res, nil
+}
+
+// Withdrawal Fee Estimate
+func (api *Api) WithdrawalFeeEstimate(req WithdrawRequest) (WithdrawalFeeEstimate, error) {
+
+	path := WITHDRAW_FUNDS_URI + strings.ToLower(req.Currency) + "/feeEstimate"
 	url := api.url + path
+	params := withdrawParams(path, req)
+
+	var res WithdrawalFeeEstimate
+
+	body, err := api.request("POST", url, params)
+	if err != nil {
+		return res, err
+	}
+
+	json.Unmarshal(body, &res)
+
+	return res, nil
+}
+
+func withdrawParams(path string, req WithdrawRequest) map[string]interface{} {
+
 	params := map[string]interface{}{
 		"request": path,
-		"nonce":   Nonce(),
-		"address": address,
-		"amount":  strconv.FormatFloat(amount, 'f', -1, 64),
+		"address": req.Address,
+		"amount":  strconv.FormatFloat(req.Amount, 'f', -1, 64),
 	}
 
-	var res WithdrawFundsResult
+	if req.ClientTransferId != "" {
+		params["client_transfer_id"] = req.ClientTransferId
+	}
+	if req.Memo != "" {
+		params["memo"] = req.Memo
+	}
+	if req.Network != "" {
+		params["network"] = req.Network
+	}
+
+	return params
+}
+
+// Transfers
+func (api *Api) Transfers(since int64, limit int) ([]Transfer, error) {
+
+	url := api.url + TRANSFERS_URI
+	params := map[string]interface{}{
+		"request":         TRANSFERS_URI,
+		"timestamp":       since,
+		"limit_transfers": limit,
+	}
+
+	var transfers []Transfer
+
+	body, err := api.request("POST", url, params)
+	if err != nil {
+		return nil, err
+	}
+
+	json.Unmarshal(body, &transfers)
+
+	return transfers, nil
+}
+
+// Account Detail
+func (api *Api) AccountDetail() (AccountDetail, error) {
+
+	url := api.url + ACCOUNT_DETAIL_URI
+	params := map[string]interface{}{
+		"request": ACCOUNT_DETAIL_URI,
+	}
+
+	var detail AccountDetail
+
+	body, err := api.request("POST", url, params)
+	if err != nil {
+		return detail, err
+	}
+
+	json.Unmarshal(body, &detail)
+
+	return detail, nil
+}
+
+// Roles
+func (api *Api) Roles() (Roles, error) {
+
+	url := api.url + ROLES_URI
+	params := map[string]interface{}{
+		"request": ROLES_URI,
+	}
+
+	var roles Roles
+
+	body, err := api.request("POST", url, params)
+	if err != nil {
+		return roles, err
+	}
+
+	json.Unmarshal(body, &roles)
+
+	return roles, nil
+}
+
+// List Accounts
+func (api *Api) ListAccounts() ([]Account, error) {
+
+	url := api.url + LIST_ACCOUNTS_URI
+	params := map[string]interface{}{
+		"request": LIST_ACCOUNTS_URI,
+	}
+
+	var accounts []Account
+
+	body, err := api.request("POST", url, params)
+	if err != nil {
+		return nil, err
+	}
+
+	json.Unmarshal(body, &accounts)
+
+	return accounts, nil
+}
+
+// Create Account
+func (api *Api) CreateAccount(name, accountType string) (Account, error) {
+
+	url := api.url + CREATE_ACCOUNT_URI
+	params := map[string]interface{}{
+		"request": CREATE_ACCOUNT_URI,
+		"name":    name,
+	}
+
+	if accountType != "" {
+		params["type"] = accountType
+	}
+
+	var account Account
+
+	body, err := api.request("POST", url, params)
+	if err != nil {
+		return account, err
+	}
+
+	json.Unmarshal(body, &account)
+
+	return account, nil
+}
+
+// Transfer Between Accounts
+func (api *Api) TransferBetweenAccounts(currency, sourceAccount, targetAccount string, amount float64) (AccountTransferResult, error) {
+
+	path := ACCOUNT_TRANSFER_URI + currency
+	url := api.url + path
+	params := map[string]interface{}{
+		"request":       path,
+		"sourceAccount": sourceAccount,
+		"targetAccount": targetAccount,
+		"amount":        strconv.FormatFloat(amount, 'f', -1, 64),
+	}
+
+	var res AccountTransferResult
 
 	body, err := api.request("POST", url, params)
 	if err != nil {
@@ -0,0 +1,134 @@
+package gemini
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Gemini cancels all orders for a session that has required heartbeats
+// enabled if no message is received within 30 seconds.
+const DEFAULT_HEARTBEAT_INTERVAL = 15 * time.Second
+
+// SessionKeeper keeps an api session alive for keys that have "require
+// heartbeat" enabled. It calls Heartbeat on an interval in the background and
+// calls CancelSession when it is stopped or after too many consecutive
+// heartbeat failures, so that open orders never outlive the process.
+type SessionKeeper struct {
+	api         *Api
+	interval    time.Duration
+	maxFailures int
+
+	// OnError, if set, is called with each heartbeat or cancel failure.
+	OnError func(error)
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+	err       error
+}
+
+// NewSessionKeeper returns a SessionKeeper for the given Api. An interval of
+// zero uses DEFAULT_HEARTBEAT_INTERVAL, and maxFailures is the number of
+// consecutive failed heartbeats after which the session is cancelled.
+func NewSessionKeeper(api *Api, interval time.Duration, maxFailures int) *SessionKeeper {
+	if interval <= 0 {
+		interval = DEFAULT_HEARTBEAT_INTERVAL
+	}
+	if maxFailures <= 0 {
+		maxFailures = 1
+	}
+
+	return &SessionKeeper{
+		api:         api,
+		interval:    interval,
+		maxFailures: maxFailures,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// Start begins sending heartbeats in a background goroutine. Calls after the
+// first, or after Stop, do nothing.
+func (k *SessionKeeper) Start() {
+	k.startOnce.Do(func() { go k.run() })
+}
+
+// Stop stops sending heartbeats and cancels the session. It blocks until the
+// background goroutine has exited and returns the reason it stopped, which is
+// nil after a clean shutdown. If Start was never called the session is
+// cancelled directly.
+func (k *SessionKeeper) Stop() error {
+	k.stopOnce.Do(func() { close(k.stop) })
+	k.startOnce.Do(func() {
+		defer close(k.done)
+		k.err = k.cancel()
+	})
+	<-k.done
+	return k.err
+}
+
+// Done returns a channel that is closed once the keeper has stopped, either
+// through Stop or after repeated heartbeat failures.
+func (k *SessionKeeper) Done() <-chan struct{} {
+	return k.done
+}
+
+// Err returns the reason the keeper stopped, once Done is closed.
+func (k *SessionKeeper) Err() error {
+	select {
+	case <-k.done:
+		return k.err
+	default:
+		return nil
+	}
+}
+
+func (k *SessionKeeper) run() {
+	defer close(k.done)
+
+	ticker := time.NewTicker(k.interval)
+	defer ticker.Stop()
+
+	var failures int
+
+	for {
+		select {
+		case <-k.stop:
+			k.err = k.cancel()
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := k.api.Heartbeat(); err != nil {
+			failures++
+			k.report(err)
+
+			if failures >= k.maxFailures {
+				k.err = fmt.Errorf("session cancelled after %d failed heartbeats: %v", failures, err)
+				if cerr := k.cancel(); cerr != nil {
+					k.err = cerr
+				}
+				return
+			}
+			continue
+		}
+
+		failures = 0
+	}
+}
+
+func (k *SessionKeeper) cancel() error {
+	if _, err := k.api.CancelSession(); err != nil {
+		k.report(err)
+		return err
+	}
+	return nil
+}
+
+func (k *SessionKeeper) report(err error) {
+	if k.OnError != nil {
+		k.OnError(err)
+	}
+}
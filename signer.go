@@ -0,0 +1,51 @@
+package gemini
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+)
+
+// Signer signs the base64 encoded payload of a private request, returning the
+// api key to send with it and the hex encoded signature. Implementations may
+// hold the secret elsewhere, such as in a separate signing process, so that
+// it is never loaded into the trading process.
+type Signer interface {
+	Sign(payload string) (key, signature string, err error)
+}
+
+// SignerFunc adapts a function to the Signer interface.
+type SignerFunc func(payload string) (key, signature string, err error)
+
+func (f SignerFunc) Sign(payload string) (string, string, error) {
+	return f(payload)
+}
+
+// hmacSigner is the default Signer. It signs with HMAC-SHA384 using the
+// credentials held by the Api, so rotated keys take effect immediately.
+type hmacSigner struct {
+	creds *credentialStore
+}
+
+func (s *hmacSigner) Sign(payload string) (string, string, error) {
+	creds := s.creds.get()
+	return creds.Key, HMACSignature(creds.Secret, payload), nil
+}
+
+// HMACSignature returns the hex encoded HMAC-SHA384 of the payload, as
+// expected by Gemini. It is provided for Signer implementations that hold
+// the secret themselves.
+func HMACSignature(secret, payload string) string {
+	mac := hmac.New(sha512.New384, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SetSigner replaces the Signer used for private requests. Passing nil
+// restores the default HMAC signer using the Api's credentials.
+func (api *Api) SetSigner(signer Signer) {
+	if signer == nil {
+		signer = &hmacSigner{creds: api.creds}
+	}
+	api.signer = signer
+}
@@ -0,0 +1,75 @@
+package gemini
+
+import (
+	"context"
+	"net/url"
+)
+
+// RequestInfo describes a request for a Tracer.
+type RequestInfo struct {
+	Method   string
+	Endpoint string
+	Params   map[string]interface{}
+}
+
+// Symbol returns the symbol the request is for, if any.
+func (r RequestInfo) Symbol() string {
+	return r.param("symbol")
+}
+
+// OrderId returns the order id the request is for, if any.
+func (r RequestInfo) OrderId() string {
+	return r.param("order_id")
+}
+
+// ClientOrderId returns the client order id the request is for, if any.
+func (r RequestInfo) ClientOrderId() string {
+	return r.param("client_order_id")
+}
+
+func (r RequestInfo) param(name string) string {
+	if s, ok := r.Params[name].(string); ok {
+		return s
+	}
+	return ""
+}
+
+// Tracer starts a span around each request. StartRequest is given the
+// context of the Api, as set with WithContext, and returns the context to
+// make the request with and a function that ends the span with the HTTP
+// status and error. See the tracing subpackage for an OpenTelemetry
+// implementation.
+type Tracer interface {
+	StartRequest(ctx context.Context, req RequestInfo) (context.Context, func(status int, err error))
+}
+
+// SetTracer sets the Tracer used for every request.
+func (api *Api) SetTracer(tracer Tracer) {
+	api.tracer = tracer
+}
+
+// WithContext returns a copy of the Api whose requests are made with the
+// given context, so that they can be cancelled and traced as part of the
+// caller's work.
+//
+//	order, err := api.WithContext(ctx).NewOrder(...)
+func (api *Api) WithContext(ctx context.Context) *Api {
+	scoped := *api
+	scoped.ctx = ctx
+	return &scoped
+}
+
+func (api *Api) context() context.Context {
+	if api.ctx == nil {
+		return context.Background()
+	}
+	return api.ctx
+}
+
+func requestInfo(verb, rawurl string, params map[string]interface{}) RequestInfo {
+	endpoint := rawurl
+	if u, err := url.Parse(rawurl); err == nil {
+		endpoint = u.Path
+	}
+	return RequestInfo{Method: verb, Endpoint: endpoint, Params: params}
+}
@@ -0,0 +1,198 @@
+// Package tracing provides OpenTelemetry tracing for the gemini package.
+//
+//	t := tracing.New(nil)
+//	api.SetTracer(t)
+//	order, err := api.WithContext(ctx).NewOrder(...)
+//
+// Each request gets a client span, a child of any span in the context given
+// to WithContext. Websocket streams are handled outside the gemini package,
+// so their messages are traced by calling StartOrderEvent and
+// StartMarketData from the stream handling code. Order event spans are
+// linked to the NewOrder span with the same client order id, and record the
+// time since the order was placed, giving end to end order latency.
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jsgoyette/gemini"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/jsgoyette/gemini/tracing"
+
+// Placed orders are kept until their final event is seen. Those whose final
+// event never arrives, such as orders placed while no stream is running,
+// are dropped after orderTTL, and the oldest are dropped beyond maxOrders.
+const (
+	orderTTL   = 24 * time.Hour
+	maxOrders  = 10000
+	pruneEvery = time.Minute
+)
+
+type placedOrder struct {
+	span trace.SpanContext
+	at   time.Time
+}
+
+type Tracer struct {
+	tracer trace.Tracer
+
+	mu        sync.Mutex
+	orders    map[string]placedOrder
+	lastPrune time.Time
+}
+
+// New returns a Tracer using the given provider, or the global provider if
+// it is nil.
+func New(provider trace.TracerProvider) *Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+
+	return &Tracer{
+		tracer: provider.Tracer(instrumentationName),
+		orders: make(map[string]placedOrder),
+	}
+}
+
+// StartRequest implements gemini.Tracer.
+func (t *Tracer) StartRequest(ctx context.Context, req gemini.RequestInfo) (context.Context, func(int, error)) {
+
+	attrs := []attribute.KeyValue{
+		attribute.String("http.request.method", req.Method),
+		attribute.String("gemini.endpoint", req.Endpoint),
+	}
+	attrs = appendOrderAttributes(attrs, req.Symbol(), req.OrderId(), req.ClientOrderId())
+
+	ctx, span := t.tracer.Start(ctx, "gemini "+req.Endpoint,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...))
+
+	placed := placedOrder{span: span.SpanContext(), at: time.Now()}
+	clientOrderId := req.ClientOrderId()
+	if req.Endpoint != gemini.NEW_ORDER_URI {
+		clientOrderId = ""
+	}
+
+	return ctx, func(status int, err error) {
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else if clientOrderId != "" {
+			// only orders that were placed will have events
+			t.remember(clientOrderId, placed)
+		}
+		span.End()
+	}
+}
+
+// remember records a placed order so that its events can be linked to it.
+func (t *Tracer) remember(clientOrderId string, placed placedOrder) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.orders) >= maxOrders || placed.at.Sub(t.lastPrune) > pruneEvery {
+		t.prune(placed.at)
+	}
+
+	t.orders[clientOrderId] = placed
+}
+
+// prune drops expired orders and, if still full, the oldest. The caller must
+// hold the lock.
+func (t *Tracer) prune(now time.Time) {
+
+	t.lastPrune = now
+
+	var oldest string
+	for id, placed := range t.orders {
+		if now.Sub(placed.at) > orderTTL {
+			delete(t.orders, id)
+			continue
+		}
+		if oldest == "" || placed.at.Before(t.orders[oldest].at) {
+			oldest = id
+		}
+	}
+
+	if len(t.orders) >= maxOrders {
+		delete(t.orders, oldest)
+	}
+}
+
+// StartOrderEvent starts a span for handling an order event. If the order was
+// placed through a traced NewOrder, the span links to it and records the
+// time since it was placed as gemini.order_latency_ms. The caller must end
+// the span.
+func (t *Tracer) StartOrderEvent(ctx context.Context, ev gemini.OrderEvent) (context.Context, trace.Span) {
+
+	attrs := []attribute.KeyValue{
+		attribute.String("gemini.event_type", ev.Type),
+	}
+	attrs = appendOrderAttributes(attrs, ev.Symbol, string(ev.OrderId), ev.ClientOrderId)
+
+	var opts []trace.SpanStartOption
+
+	t.mu.Lock()
+	placed, ok := t.orders[ev.ClientOrderId]
+	if ok && time.Since(placed.at) > orderTTL {
+		delete(t.orders, ev.ClientOrderId)
+		ok = false
+	}
+	if ok && isFinal(ev) {
+		delete(t.orders, ev.ClientOrderId)
+	}
+	t.mu.Unlock()
+
+	if ok {
+		opts = append(opts, trace.WithLinks(trace.Link{SpanContext: placed.span}))
+		attrs = append(attrs, attribute.Int64("gemini.order_latency_ms", time.Since(placed.at).Milliseconds()))
+	}
+
+	opts = append(opts, trace.WithSpanKind(trace.SpanKindConsumer), trace.WithAttributes(attrs...))
+
+	return t.tracer.Start(ctx, "gemini order event "+ev.Type, opts...)
+}
+
+// StartMarketData starts a span for handling a market data message for a
+// symbol. The caller must end the span.
+func (t *Tracer) StartMarketData(ctx context.Context, symbol string, md gemini.MarketData) (context.Context, trace.Span) {
+	return t.tracer.Start(ctx, "gemini market data "+md.Type,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("gemini.symbol", symbol),
+			attribute.String("gemini.event_id", string(md.EventId)),
+			attribute.Int("gemini.event_count", len(md.Events)),
+		))
+}
+
+func appendOrderAttributes(attrs []attribute.KeyValue, symbol, orderId, clientOrderId string) []attribute.KeyValue {
+	if symbol != "" {
+		attrs = append(attrs, attribute.String("gemini.symbol", symbol))
+	}
+	if orderId != "" {
+		attrs = append(attrs, attribute.String("gemini.order_id", orderId))
+	}
+	if clientOrderId != "" {
+		attrs = append(attrs, attribute.String("gemini.client_order_id", clientOrderId))
+	}
+	return attrs
+}
+
+// isFinal reports whether no further events are expected for the order.
+func isFinal(ev gemini.OrderEvent) bool {
+	switch ev.Type {
+	case "closed", "cancelled", "rejected":
+		return true
+	case "fill":
+		return ev.RemainingAmount == 0
+	}
+	return false
+}
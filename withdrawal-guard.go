@@ -0,0 +1,313 @@
+package gemini
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+type WithdrawalRejectError struct {
+	Currency string
+	Check    string
+	Reason   string
+}
+
+func (e *WithdrawalRejectError) Error() string {
+	return fmt.Sprintf("[%v] %v: %v", e.Check, e.Currency, e.Reason)
+}
+
+type WithdrawalRequest struct {
+	RequestId string `json:"request_id"`
+	WithdrawRequest
+	Requested time.Time `json:"requested"`
+}
+
+// WithdrawalAuditEntry is a single line of the audit log.
+type WithdrawalAuditEntry struct {
+	Time   time.Time            `json:"time"`
+	Action string               `json:"action"`
+	Req    WithdrawalRequest    `json:"request"`
+	Result *WithdrawFundsResult `json:"result,omitempty"`
+	Error  string               `json:"error,omitempty"`
+}
+
+// WithdrawalGuard puts safeguards in front of Withdraw. A withdrawal is
+// first requested, which checks the destination against an allowlist and the
+// amount against a per-currency daily limit, and is only sent once approved
+// with a separate call to Approve. Every request, approval and result is
+// appended to the audit log as a line of JSON; a withdrawal is neither held
+// nor sent unless its request and approval have been written.
+type WithdrawalGuard struct {
+	api   *Api
+	audit io.Writer
+
+	// OnError, if set, is called when an audit entry that cannot stop the
+	// withdrawal, such as its result, fails to be written.
+	OnError func(error)
+
+	mu        sync.Mutex
+	allowlist map[string]map[string]bool
+	limits    map[string]float64
+	withdrawn map[string]float64
+	day       string
+	pending   map[string]WithdrawalRequest
+}
+
+func NewWithdrawalGuard(api *Api, audit io.Writer) *WithdrawalGuard {
+	return &WithdrawalGuard{
+		api:       api,
+		audit:     audit,
+		allowlist: make(map[string]map[string]bool),
+		limits:    make(map[string]float64),
+		withdrawn: make(map[string]float64),
+		pending:   make(map[string]WithdrawalRequest),
+	}
+}
+
+// Allow adds a destination address for a currency to the allowlist.
+func (g *WithdrawalGuard) Allow(currency, address string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	currency = strings.ToLower(currency)
+	if g.allowlist[currency] == nil {
+		g.allowlist[currency] = make(map[string]bool)
+	}
+	g.allowlist[currency][address] = true
+}
+
+// Disallow removes a destination address for a currency from the allowlist.
+func (g *WithdrawalGuard) Disallow(currency, address string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.allowlist[strings.ToLower(currency)], address)
+}
+
+// SyncAllowlist adds every active address on Gemini's approved address list
+// for the network to the allowlist for the currency.
+func (g *WithdrawalGuard) SyncAllowlist(network, currency string) error {
+
+	addresses, err := g.api.ApprovedAddresses(network)
+	if err != nil {
+		return err
+	}
+
+	for _, address := range addresses {
+		if address.Status == "active" {
+			g.Allow(currency, address.Address)
+		}
+	}
+
+	return nil
+}
+
+// SetDailyLimit sets the most of a currency that may be withdrawn in a UTC
+// day. A limit of zero removes it.
+func (g *WithdrawalGuard) SetDailyLimit(currency string, limit float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.limits[strings.ToLower(currency)] = limit
+}
+
+// Request checks a withdrawal against the allowlist and daily limit and, if
+// it passes, holds it until it is approved. It returns the request id to
+// pass to Approve.
+func (g *WithdrawalGuard) Request(wr WithdrawRequest) (string, error) {
+
+	wr.Currency = strings.ToLower(wr.Currency)
+
+	req := WithdrawalRequest{
+		RequestId:       fmt.Sprintf("wd-%d", Nonce()),
+		WithdrawRequest: wr,
+		Requested:       time.Now().UTC(),
+	}
+
+	g.mu.Lock()
+	err := g.check(req)
+	if err == nil {
+		g.pending[req.RequestId] = req
+	}
+	g.mu.Unlock()
+
+	if err != nil {
+		g.report(g.log("rejected", req, nil, err))
+		return "", err
+	}
+
+	if err := g.log("requested", req, nil, nil); err != nil {
+		g.mu.Lock()
+		delete(g.pending, req.RequestId)
+		g.mu.Unlock()
+		return "", err
+	}
+
+	return req.RequestId, nil
+}
+
+// Pending returns the withdrawals awaiting approval.
+func (g *WithdrawalGuard) Pending() []WithdrawalRequest {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	pending := make([]WithdrawalRequest, 0, len(g.pending))
+	for _, req := range g.pending {
+		pending = append(pending, req)
+	}
+	return pending
+}
+
+// Approve sends a requested withdrawal. The checks are repeated since the
+// allowlist or limits may have changed since it was requested. If the
+// approval cannot be written to the audit log the withdrawal is not sent and
+// stays pending. The amount counts towards the daily limit unless Gemini
+// rejects the withdrawal with an *ApiError; after any other failure it may
+// still have been sent.
+func (g *WithdrawalGuard) Approve(requestId string) (WithdrawFundsResult, error) {
+
+	g.mu.Lock()
+	req, ok := g.pending[requestId]
+	if !ok {
+		g.mu.Unlock()
+		return WithdrawFundsResult{}, fmt.Errorf("no pending withdrawal %v", requestId)
+	}
+
+	delete(g.pending, requestId)
+
+	err := g.check(req)
+	if err == nil {
+		// count the amount before sending so concurrent approvals cannot
+		// exceed the limit together
+		g.withdrawn[req.Currency] += req.Amount
+	}
+	day := g.day
+	g.mu.Unlock()
+
+	if err != nil {
+		g.report(g.log("rejected", req, nil, err))
+		return WithdrawFundsResult{}, err
+	}
+
+	if err := g.log("approved", req, nil, nil); err != nil {
+		g.mu.Lock()
+		g.uncount(req, day)
+		g.pending[req.RequestId] = req
+		g.mu.Unlock()
+		return WithdrawFundsResult{}, err
+	}
+
+	res, err := g.api.Withdraw(req.WithdrawRequest)
+	if err != nil {
+		if _, ok := err.(*ApiError); ok {
+			g.mu.Lock()
+			g.uncount(req, day)
+			g.mu.Unlock()
+		}
+
+		g.report(g.log("failed", req, nil, err))
+		return res, err
+	}
+
+	g.report(g.log("sent", req, &res, nil))
+
+	return res, nil
+}
+
+// Deny discards a requested withdrawal.
+func (g *WithdrawalGuard) Deny(requestId string) error {
+
+	g.mu.Lock()
+	req, ok := g.pending[requestId]
+	delete(g.pending, requestId)
+	g.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("no pending withdrawal %v", requestId)
+	}
+
+	g.report(g.log("denied", req, nil, nil))
+
+	return nil
+}
+
+// uncount removes a withdrawal counted on the given day from the daily total,
+// unless the day has since rolled over. The caller must hold the lock.
+func (g *WithdrawalGuard) uncount(req WithdrawalRequest, day string) {
+	if g.day != day {
+		return
+	}
+	g.withdrawn[req.Currency] -= req.Amount
+	if g.withdrawn[req.Currency] < 0 {
+		g.withdrawn[req.Currency] = 0
+	}
+}
+
+// check validates a withdrawal. The caller must hold the lock.
+func (g *WithdrawalGuard) check(req WithdrawalRequest) error {
+
+	if day := time.Now().UTC().Format("2006-01-02"); day != g.day {
+		g.day = day
+		g.withdrawn = make(map[string]float64)
+	}
+
+	if !g.allowlist[req.Currency][req.Address] {
+		return &WithdrawalRejectError{
+			Currency: req.Currency,
+			Check:    "allowlist",
+			Reason:   fmt.Sprintf("address %v is not on the allowlist", req.Address),
+		}
+	}
+
+	limit := g.limits[req.Currency]
+	if total := g.withdrawn[req.Currency] + req.Amount; limit > 0 && total > limit {
+		return &WithdrawalRejectError{
+			Currency: req.Currency,
+			Check:    "daily_limit",
+			Reason:   fmt.Sprintf("withdrawing %v would exceed the daily limit of %v", req.Amount, limit),
+		}
+	}
+
+	return nil
+}
+
+// log appends an entry to the audit log.
+func (g *WithdrawalGuard) log(action string, req WithdrawalRequest, res *WithdrawFundsResult, err error) error {
+
+	if g.audit == nil {
+		return nil
+	}
+
+	entry := WithdrawalAuditEntry{
+		Time:   time.Now().UTC(),
+		Action: action,
+		Req:    req,
+		Result: res,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	line, merr := json.Marshal(entry)
+	if merr != nil {
+		return fmt.Errorf("audit %v %v: %v", action, req.RequestId, merr)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, werr := g.audit.Write(append(line, '\n')); werr != nil {
+		return fmt.Errorf("audit %v %v: %v", action, req.RequestId, werr)
+	}
+
+	return nil
+}
+
+func (g *WithdrawalGuard) report(err error) {
+	if err != nil && g.OnError != nil {
+		g.OnError(err)
+	}
+}
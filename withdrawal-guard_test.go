@@ -0,0 +1,220 @@
+package gemini
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// auditLog accepts audit entries, failing to write those with the given
+// action.
+type auditLog struct {
+	failOn string
+}
+
+func (a *auditLog) Write(p []byte) (int, error) {
+	if a.failOn != "" && bytes.Contains(p, []byte(`"action":"`+a.failOn+`"`)) {
+		return 0, errors.New("disk full")
+	}
+	return len(p), nil
+}
+
+// fakeWithdrawals is an exchange accepting withdrawals of btc, or rejecting
+// them with reject if set.
+type fakeWithdrawals struct {
+	sent   int
+	reject bool
+}
+
+func (f *fakeWithdrawals) handlers() map[string]fakeHandler {
+	return map[string]fakeHandler{
+		WITHDRAW_FUNDS_URI + "btc/feeEstimate": func(map[string]interface{}) (int, interface{}) {
+			return http.StatusOK, map[string]interface{}{
+				"currency": "BTC",
+				"fee":      map[string]string{"currency": "BTC", "value": "0.0001"},
+			}
+		},
+		WITHDRAW_FUNDS_URI + "btc": func(params map[string]interface{}) (int, interface{}) {
+			if f.reject {
+				return apiError("InsufficientFunds", "Insufficient funds")
+			}
+			f.sent++
+			return http.StatusOK, map[string]string{
+				"address":      params["address"].(string),
+				"amount":       params["amount"].(string),
+				"withdrawalId": "w1",
+			}
+		},
+	}
+}
+
+func newTestGuard(t *testing.T, audit *auditLog) (*WithdrawalGuard, *fakeWithdrawals) {
+	f := &fakeWithdrawals{}
+	g := NewWithdrawalGuard(fakeGemini(t, f.handlers()), audit)
+	g.Allow("BTC", "addr1")
+	return g, f
+}
+
+func isWithdrawalReject(err error, check string) bool {
+	var reject *WithdrawalRejectError
+	return errors.As(err, &reject) && reject.Check == check
+}
+
+func TestWithdrawalGuardAllowlist(t *testing.T) {
+
+	g, f := newTestGuard(t, &auditLog{})
+
+	_, err := g.Request(WithdrawRequest{Currency: "btc", Address: "addr2", Amount: 1})
+	if !isWithdrawalReject(err, "allowlist") {
+		t.Errorf("got %v, want an allowlist reject", err)
+	}
+
+	id, err := g.Request(WithdrawRequest{Currency: "BTC", Address: "addr1", Amount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// removed from the allowlist between request and approval
+	g.Disallow("btc", "addr1")
+
+	if _, err := g.Approve(id); !isWithdrawalReject(err, "allowlist") {
+		t.Errorf("got %v, want an allowlist reject", err)
+	}
+	if f.sent != 0 {
+		t.Errorf("%d withdrawals sent, want 0", f.sent)
+	}
+}
+
+func TestWithdrawalGuardDailyLimit(t *testing.T) {
+
+	g, f := newTestGuard(t, &auditLog{})
+	g.SetDailyLimit("btc", 1)
+
+	req := WithdrawRequest{Currency: "btc", Address: "addr1", Amount: 0.6}
+
+	id, err := g.Request(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Approve(id); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := g.Request(req); !isWithdrawalReject(err, "daily_limit") {
+		t.Errorf("got %v, want a daily_limit reject", err)
+	}
+
+	// the next UTC day starts a new total
+	g.mu.Lock()
+	g.day = "2000-01-01"
+	g.mu.Unlock()
+
+	id, err = g.Request(req)
+	if err != nil {
+		t.Fatalf("after rollover: %v", err)
+	}
+	if _, err := g.Approve(id); err != nil {
+		t.Fatalf("after rollover: %v", err)
+	}
+	if f.sent != 2 {
+		t.Errorf("%d withdrawals sent, want 2", f.sent)
+	}
+}
+
+func TestWithdrawalGuardRequestAuditFailure(t *testing.T) {
+
+	g, _ := newTestGuard(t, &auditLog{failOn: "requested"})
+
+	if _, err := g.Request(WithdrawRequest{Currency: "btc", Address: "addr1", Amount: 1}); err == nil {
+		t.Error("request held without an audit entry")
+	}
+	if n := len(g.Pending()); n != 0 {
+		t.Errorf("%d withdrawals pending, want 0", n)
+	}
+}
+
+func TestWithdrawalGuardApproveAuditFailure(t *testing.T) {
+
+	audit := &auditLog{failOn: "approved"}
+	g, f := newTestGuard(t, audit)
+	g.SetDailyLimit("btc", 1)
+
+	id, err := g.Request(WithdrawRequest{Currency: "btc", Address: "addr1", Amount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := g.Approve(id); err == nil {
+		t.Error("withdrawal approved without an audit entry")
+	}
+	if f.sent != 0 {
+		t.Errorf("%d withdrawals sent, want 0", f.sent)
+	}
+	if n := len(g.Pending()); n != 1 {
+		t.Errorf("%d withdrawals pending, want 1", n)
+	}
+
+	// the amount was not counted, so the approval can be retried in full
+	audit.failOn = ""
+	if _, err := g.Approve(id); err != nil {
+		t.Errorf("retried approval: %v", err)
+	}
+	if f.sent != 1 {
+		t.Errorf("%d withdrawals sent, want 1", f.sent)
+	}
+}
+
+func TestWithdrawalGuardFailedWithdrawal(t *testing.T) {
+
+	g, f := newTestGuard(t, &auditLog{})
+	g.SetDailyLimit("btc", 1)
+
+	var failed []error
+	g.OnError = func(err error) { failed = append(failed, err) }
+
+	req := WithdrawRequest{Currency: "btc", Address: "addr1", Amount: 1}
+
+	// rejected by Gemini, so not sent and not counted
+	f.reject = true
+
+	id, err := g.Request(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var apiErr *ApiError
+	if _, err := g.Approve(id); !errors.As(err, &apiErr) {
+		t.Fatalf("got %v, want an *ApiError", err)
+	}
+	if g.withdrawn["btc"] != 0 {
+		t.Errorf("rejected withdrawal counted: %v", g.withdrawn["btc"])
+	}
+
+	// failed in transport, so it may have been sent and stays counted
+	f.reject = false
+	g.api.Use(func(next Handler) Handler {
+		return func(r *Request) (*Response, error) {
+			if r.Endpoint == WITHDRAW_FUNDS_URI+"btc" {
+				return nil, errors.New("connection reset")
+			}
+			return next(r)
+		}
+	})
+
+	id, err = g.Request(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Approve(id); err == nil {
+		t.Fatal("expected the transport error")
+	}
+	if g.withdrawn["btc"] != 1 {
+		t.Errorf("withdrawn %v after a transport error, want 1", g.withdrawn["btc"])
+	}
+	if _, err := g.Request(req); !isWithdrawalReject(err, "daily_limit") {
+		t.Errorf("got %v, want a daily_limit reject", err)
+	}
+	if len(failed) != 0 {
+		t.Errorf("audit errors reported: %v", failed)
+	}
+}